@@ -0,0 +1,183 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// action is a user command. Keys are bound to actions through keymaps
+// built from the registry below and the config file; mouse targets can
+// trigger actions too.
+type action int
+
+const (
+	actionNone action = iota
+	actionUp
+	actionDown
+	actionPageUp
+	actionPageDown
+	actionHome
+	actionEnd
+	actionBack
+	actionOpen
+	actionCancel
+	actionQuit
+	actionKeep
+	actionNextCopy
+	actionNextDup
+	actionPrevDup
+	actionRescan
+	actionPause
+	actionGroups
+	actionDupsOnly
+	actionCopies
+	actionOverlaps
+	actionMark
+	actionMarkDups
+	actionInvertMarks
+	actionClearMarks
+	actionDelete
+	actionTrash
+	actionLink
+	actionExport
+	actionSearch
+	actionFilter
+	actionFind
+	actionHelp
+	actionConfirm
+	actionDeny
+)
+
+type actionDef struct {
+	action action
+	name   string
+	keys   []string
+	help   string
+	dialog bool
+}
+
+// actions is the registry of every action with its config name,
+// default keys and help text.
+var actions = []actionDef{
+	{action: actionUp, name: "up", keys: []string{"up", "k"}, help: "Move up"},
+	{action: actionDown, name: "down", keys: []string{"down", "j"}, help: "Move down"},
+	{action: actionPageUp, name: "page_up", keys: []string{"pgup"}, help: "Page up"},
+	{action: actionPageDown, name: "page_down", keys: []string{"pgdown"}, help: "Page down"},
+	{action: actionHome, name: "home", keys: []string{"home", "g"}, help: "First row"},
+	{action: actionEnd, name: "end", keys: []string{"end", "G"}, help: "Last row"},
+	{action: actionBack, name: "back", keys: []string{"left", "h"}, help: "Parent folder, or back to the previous screen"},
+	{action: actionOpen, name: "open", keys: []string{"right", "l"}, help: "Open the selected folder, or show the selected file in its folder"},
+	{action: actionCancel, name: "cancel", keys: []string{"esc"}, help: "Close the screen or the search; quit from the folder view"},
+	{action: actionQuit, name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	{action: actionKeep, name: "keep", keys: []string{"enter"}, help: "Keep the selected copy and remove the others"},
+	{action: actionNextCopy, name: "next_copy", keys: []string{"tab"}, help: "Go to the next copy of the selected file"},
+	{action: actionNextDup, name: "next_duplicate", keys: []string{"n"}, help: "Go to the next duplicate"},
+	{action: actionPrevDup, name: "previous_duplicate", keys: []string{"N"}, help: "Go to the previous duplicate"},
+	{action: actionRescan, name: "rescan", keys: []string{"r"}, help: "Rescan the archive"},
+	{action: actionPause, name: "pause", keys: []string{"p"}, help: "Pause or resume hashing"},
+	{action: actionGroups, name: "groups", keys: []string{"d"}, help: "Show or close the duplicate groups"},
+	{action: actionDupsOnly, name: "duplicates_only", keys: []string{"D"}, help: "Show only paths leading to duplicates"},
+	{action: actionCopies, name: "copies", keys: []string{"c"}, help: "Choose a copy of the selected file to keep"},
+	{action: actionOverlaps, name: "overlaps", keys: []string{"o"}, help: "Show or close the overlapping folders"},
+	{action: actionMark, name: "mark", keys: []string{"space", "insert"}, help: "Mark or unmark the selected file or folder"},
+	{action: actionMarkDups, name: "mark_duplicates", keys: []string{"a"}, help: "Mark the duplicates in the current folder"},
+	{action: actionInvertMarks, name: "invert_marks", keys: []string{"i"}, help: "Invert the marks in the current folder"},
+	{action: actionClearMarks, name: "clear_marks", keys: []string{"u"}, help: "Clear all marks"},
+	{action: actionDelete, name: "delete", keys: []string{"X"}, help: "Delete the marked files"},
+	{action: actionTrash, name: "trash", keys: []string{"T"}, help: "Move the marked files to the trash"},
+	{action: actionLink, name: "link", keys: []string{"L"}, help: "Replace the marked files with hard links"},
+	{action: actionExport, name: "export", keys: []string{"E"}, help: "Export the marked files to CSV"},
+	{action: actionSearch, name: "search", keys: []string{"/"}, help: "Search the current folder"},
+	{action: actionFilter, name: "filter", keys: []string{"f"}, help: "Filter files by name, extension, size or duplicates"},
+	{action: actionFind, name: "find", keys: []string{"F"}, help: "Find files anywhere in the archive"},
+	{action: actionHelp, name: "help", keys: []string{"?"}, help: "Show or close this help"},
+	{action: actionConfirm, name: "confirm", keys: []string{"y"}, help: "Confirm", dialog: true},
+	{action: actionDeny, name: "deny", keys: []string{"n", "esc"}, help: "Cancel", dialog: true},
+}
+
+// mouseHelp describes the mouse interactions for the help screen.
+var mouseHelp = [][2]string{
+	{"click", "Select a row, a group, an overlap or a copy in the copies panel"},
+	{"double-click", "Open a folder, a duplicate group, an overlap or a search result"},
+	{"click header", "Sort by the column; click again to reverse the order"},
+	{"click path", "Go to a folder in the breadcrumbs"},
+	{"click status", "Show the duplicate groups"},
+	{"wheel", "Scroll the list"},
+}
+
+// keymap binds keys, as reported by tea.KeyMsg.String, to actions.
+type keymap map[string]action
+
+// newKeymaps builds the keymaps of the main screens and of the confirmation
+// dialog. Bindings replace the default keys of the actions they name.
+func newKeymaps(bindings map[string][]string) (keys, dialogKeys keymap, err error) {
+	for name := range bindings {
+		if !slices.ContainsFunc(actions, func(def actionDef) bool { return def.name == name }) {
+			return nil, nil, fmt.Errorf("unknown action %q in key bindings", name)
+		}
+	}
+
+	keys, dialogKeys = keymap{}, keymap{}
+	for _, def := range actions {
+		km := keys
+		if def.dialog {
+			km = dialogKeys
+		}
+		defKeys := def.keys
+		if bound, ok := bindings[def.name]; ok {
+			defKeys = bound
+		}
+		for _, key := range defKeys {
+			key = keyName(key)
+			if other, ok := km[key]; ok {
+				return nil, nil, fmt.Errorf("key %q is bound to both %q and %q", key, other, def.name)
+			}
+			km[key] = def.action
+		}
+	}
+	return keys, dialogKeys, nil
+}
+
+// keyName maps the names used in the config to the names tea reports.
+func keyName(key string) string {
+	if strings.EqualFold(key, "space") {
+		return " "
+	}
+	return key
+}
+
+func (a action) String() string {
+	for _, def := range actions {
+		if def.action == a {
+			return def.name
+		}
+	}
+	return "none"
+}
+
+// keysOf returns the keys bound to a in km, as shown to the user.
+func (km keymap) keysOf(a action) []string {
+	var result []string
+	for key, bound := range km {
+		if bound == a {
+			if key == " " {
+				key = "space"
+			}
+			result = append(result, key)
+		}
+	}
+	// Named keys such as "enter" come before the letters bound next to them.
+	slices.SortFunc(result, func(a, b string) int {
+		if (len(a) == 1) != (len(b) == 1) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	})
+	return result
+}
+
+// keyHint returns the keys bound to a for hints in the UI.
+func (app *app) keyHint(a action) string {
+	return strings.Join(app.keys.keysOf(a), "/")
+}
@@ -0,0 +1,26 @@
+package app
+
+import "testing"
+
+func TestKeymaps(t *testing.T) {
+	keys, dialogKeys, err := newKeymaps(map[string][]string{"down": {"space"}, "mark": {"m"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys[" "] != actionDown || keys["m"] != actionMark || keys["j"] != actionNone {
+		t.Errorf("bindings not replaced: space %v, m %v, j %v", keys[" "], keys["m"], keys["j"])
+	}
+	if keys["k"] != actionUp || dialogKeys["n"] != actionDeny || keys["n"] != actionNextDup {
+		t.Error("default bindings lost")
+	}
+	if dialogKeys["y"] != actionConfirm || dialogKeys["enter"] == actionConfirm {
+		t.Error("the key that asks to keep a copy also confirms removing the others")
+	}
+
+	if _, _, err := newKeymaps(map[string][]string{"up": {"n"}}); err == nil {
+		t.Error("conflicting binding accepted")
+	}
+	if _, _, err := newKeymaps(map[string][]string{"jump": {"x"}}); err == nil {
+		t.Error("unknown action accepted")
+	}
+}
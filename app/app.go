@@ -1,40 +1,106 @@
 package app
 
 import (
+	"dedup/config"
 	"dedup/fs"
-	"log"
-	"path/filepath"
+	"os"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-func Run(fsys fs.FS) {
+type Options struct {
+	// Headless runs without the TUI, reporting progress to stderr
+	// and reading commands from stdin.
+	Headless bool
+
+	// SkipConfirm removes files without asking for confirmation first.
+	SkipConfirm bool
+
+	// Protected paths always keep their files.
+	Protected fs.Protected
+
+	// ReadOnly disables every action that changes the archive.
+	ReadOnly bool
+
+	// Keys maps action names to the keys that replace their default bindings.
+	Keys map[string][]string
+
+	// Theme names the colour theme; Colors overrides its styles by name.
+	Theme  string
+	Colors map[string]config.Color
+
+	// Columns names the columns of the file list after the name;
+	// nil shows the modification time and size.
+	Columns []string
+}
+
+func Run(fsys fs.FS, options Options) error {
+	keys, dialogKeys, err := newKeymaps(options.Keys)
+	if err != nil {
+		return err
+	}
+	theme, err := newTheme(options.Theme, options.Colors)
+	if err != nil {
+		return err
+	}
+	columns, err := newColumns(options.Columns)
+	if err != nil {
+		return err
+	}
+
 	m := make(model, 1)
-	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	var p *tea.Program
+	if options.Headless {
+		p = tea.NewProgram(m, tea.WithoutRenderer(), tea.WithInput(nil))
+	} else {
+		p = tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	}
 
 	rootFolder := &file{
 		folder: &folder{
-			sortAscending: []bool{true, true, true},
+			sortAscending: newSortOrder(),
 		},
 	}
 
 	app := &app{
-		fs:         fsys,
-		rootFolder: rootFolder,
-		curFolder:  rootFolder,
-		byHash:     map[string][]*file{},
-		events:     events{p},
+		fs:          fsys,
+		rootFolder:  rootFolder,
+		curFolder:   rootFolder,
+		byHash:      map[string][]*file{},
+		byFolder:    map[string][]*file{},
+		dirty:       map[*file]bool{},
+		duplicated:  map[string]struct{}{},
+		bySignature: map[string][]*file{},
+		unsigned:    map[*file]struct{}{},
+		regroup:     map[string]struct{}{},
+		groupList:   newGroupList(),
+		marked:      map[*file]struct{}{},
+		visible:     map[*file]files{},
+		matching:    map[*file]bool{},
+		shown:       map[*file]*file{},
+		events:      events{p},
+		headless:    options.Headless,
+		skipConfirm: options.SkipConfirm,
+		protected:   options.Protected,
+		readOnly:    options.ReadOnly,
+		keys:        keys,
+		dialogKeys:  dialogKeys,
+		theme:       theme,
+		columns:     columns,
 	}
 
 	fsys.Scan(app.events)
+	if options.Headless {
+		go readCommands(app.events, os.Stdin)
+		go watchSignals(app.events)
+	}
 
 	m <- app
 
-	if _, err := p.Run(); err != nil {
-		log.Fatal(err)
-	}
+	_, err = p.Run()
+	return err
 }
 
 type events struct {
@@ -55,14 +121,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	app := <-m
 	defer func() { m <- app }()
 	defer func() {
-		if app.curFolder.selectedIdx >= len(app.curFolder.children) {
-			app.curFolder.selectedIdx = len(app.curFolder.children) - 1
+		app.flushDirty()
+		children := app.children(app.curFolder)
+		if app.curFolder.selectedIdx >= len(children) {
+			app.curFolder.selectedIdx = len(children) - 1
 		}
 		if app.curFolder.selectedIdx < 0 {
 			app.curFolder.selectedIdx = 0
 		}
-		if app.curFolder.offsetIdx >= len(app.curFolder.children)+4-app.screenHeight {
-			app.curFolder.offsetIdx = len(app.curFolder.children) + 4 - app.screenHeight
+		if app.curFolder.offsetIdx >= len(children)-app.folderHeight() {
+			app.curFolder.offsetIdx = len(children) - app.folderHeight()
 		}
 		if app.curFolder.offsetIdx < 0 {
 			app.curFolder.offsetIdx = 0
@@ -75,85 +143,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		app.screenWidth = msg.Width
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "esc":
-			return m, tea.Quit
-		case "up":
-			app.curFolder.selectedIdx--
-
-		case "down":
-			app.curFolder.selectedIdx++
-
-		case "pgup":
-			app.curFolder.selectedIdx -= app.screenHeight - 4
-			app.curFolder.offsetIdx -= app.screenHeight - 4
-
-		case "pgdown":
-			app.curFolder.selectedIdx += app.screenHeight - 4
-			app.curFolder.offsetIdx += app.screenHeight - 4
-
-		case "home":
-			app.curFolder.selectedIdx = 0
-			app.curFolder.offsetIdx = 0
-
-		case "end":
-			app.curFolder.selectedIdx = len(app.curFolder.children) - 1
-			app.curFolder.offsetIdx = len(app.curFolder.children) + 4 - app.screenHeight
-
-		case "left":
-			if app.curFolder.parent != nil {
-				app.curFolder = app.curFolder.parent
-			}
-		case "right":
-			child := app.curFolder.children[app.curFolder.selectedIdx]
-			if child.folder != nil {
-				app.curFolder = child
-				break
-			}
-
-		case "tab":
-			file := app.curFolder.children[app.curFolder.selectedIdx]
-			if file.folder != nil {
-				break
-			}
-			files := app.byHash[file.hash]
-			if len(files) < 2 {
-				break
-			}
-			i := 0
-			for ; files[i] != file; i++ {
-			}
-			nextFile := files[(i+1)%len(files)]
-			app.curFolder = nextFile.parent
-			for idx, child := range app.curFolder.children {
-				if child == nextFile {
-					app.curFolder.selectedIdx = idx
-					break
-				}
-			}
-		case "enter":
-			file := app.curFolder.children[app.curFolder.selectedIdx]
-			if file.folder != nil {
-				break
-			}
-			files := app.byHash[file.hash]
-			if len(files) < 2 {
-				break
-			}
-			for _, dup := range files {
-				if dup != file {
-					app.fs.Remove(filepath.Join(dup.fullPath()...))
-					app.deleteFile(dup)
-				}
-			}
-			for idx, child := range app.curFolder.children {
-				if child == file {
-					app.curFolder.selectedIdx = idx
-					break
-				}
-			}
-			app.analyze()
+		app.message = ""
+		if app.confirmation != nil {
+			app.handleConfirmKey(msg.String())
+			return m, nil
 		}
+		if app.prompt != nil {
+			app.handlePromptKey(msg)
+			return m, nil
+		}
+		return m, app.handleAction(app.keys[msg.String()])
 
 	case tea.MouseMsg:
 		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
@@ -161,20 +160,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if msg.X >= target.x1 && msg.X <= target.x2 && msg.Y >= target.y1 && msg.Y <= target.y2 {
 					switch cmd := target.cmd.(type) {
 					case selectFolder:
-						app.curFolder = app.findFile(cmd.path)
+						if folder := app.findFile(cmd.path); folder != nil {
+							app.curFolder = folder
+						}
 
 					case selectFile:
 						app.curFolder.selectedIdx = cmd.idx
-						file := app.curFolder.children[cmd.idx]
-						if file.folder != nil &&
-							app.lastX == msg.X && app.lastY == msg.Y &&
-							time.Since(app.lastClickTime).Milliseconds() < 500 {
-
+						file := app.selectedFile()
+						if app.isDoubleClick(msg) && file != nil && file.folder != nil {
 							app.curFolder = file
 						}
-						app.lastClickTime = time.Now()
-						app.lastX = msg.X
-						app.lastY = msg.Y
 
 					case sortCmd:
 						if cmd.column == app.curFolder.sortColumn {
@@ -183,48 +178,308 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							app.curFolder.sortColumn = cmd.column
 						}
 						app.curFolder.sort()
+						app.invalidateView()
+
+					case action:
+						return m, app.handleAction(cmd)
+
+					case selectGroup:
+						app.groupList.selectedIdx = cmd.idx
+						if app.isDoubleClick(msg) {
+							app.openGroup(cmd.idx)
+						}
+
+					case groupSortCmd:
+						app.groupList.sortBy(cmd.column)
+
+					case selectOverlap:
+						app.overlaps.selectedIdx = cmd.idx
+						if app.isDoubleClick(msg) {
+							app.openOverlap(cmd.idx)
+						}
+
+					case selectResult:
+						app.resultCursor.selectedIdx = cmd.idx
+						if app.isDoubleClick(msg) {
+							app.revealFile(app.results[cmd.idx])
+						}
+
+					case selectMember:
+						if cmd.panel {
+							app.panel.focused = true
+							app.panel.selectedIdx = cmd.idx
+						} else {
+							app.members.selectedIdx = cmd.idx
+						}
 					}
 					break
 				}
 			}
 		} else if msg.Button == tea.MouseButtonWheelUp {
-			app.curFolder.offsetIdx--
+			app.scroll(-1)
 		} else if msg.Button == tea.MouseButtonWheelDown {
-			app.curFolder.offsetIdx++
+			app.scroll(1)
 		}
 
 	case fs.FileMetas:
-		for _, meta := range msg {
-			path, name := parseName(meta.Path)
-			incoming := &file{
-				name:    name,
-				size:    meta.Size,
-				modTime: meta.ModTime,
-				hash:    meta.Hash,
-			}
-			folder := app.getFile(path)
-			folder.children = append(folder.children, incoming)
-			incoming.parent = folder
-			if meta.Hash == "" {
-				app.hashing++
-			}
+		app.mergeMetas(msg)
+
+	case fs.ScanProgress:
+		app.scanProgress = msg
+
+	case fs.ArchiveScanned:
+		app.sweep()
+		app.hashStart = time.Now()
+		app.report("scanned %d files in %d folders, %s", app.scanProgress.Files, app.scanProgress.Dirs,
+			formatBytes(app.scanProgress.Bytes))
+
+	case fs.FileAdded:
+		file := app.findFile(parsePath(msg.Path))
+		if file != nil && file.folder == nil {
+			app.updateMeta(file, fs.FileMeta(msg))
+		} else {
+			app.addMeta(fs.FileMeta(msg))
+		}
+
+	case fs.FileModified:
+		file := app.findFile(parsePath(msg.Path))
+		if file == nil || file.folder != nil {
+			break
 		}
-		app.rootFolder.updateMetas()
-		app.rootFolder.sortRec()
+		app.updateMeta(file, fs.FileMeta(msg))
+
+	case fs.FileRemoved:
+		file := app.findFile(parsePath(msg.Path))
+		if file == nil || file == app.rootFolder {
+			break
+		}
+		selected := app.selectedFile()
+		app.removeFile(file)
+		app.flushDirty()
+		app.selectFile(selected)
 
 	case fs.FileHashed:
 		file := app.findFile(parsePath(msg.Path))
-		file.hash = msg.Hash
-		app.hashed++
+		if file != nil {
+			app.unindexFile(file)
+			file.hash = msg.Hash
+			app.indexFile(file)
+			if columns[file.parent.sortColumn].volatile {
+				app.markDirty(file, false)
+			}
+		}
+		if app.state == archiveReady {
+			app.hashStart = time.Now()
+		}
 		app.state = archiveHashing
+		app.hashed++
+		app.hashedBytes += msg.Size
+		if time.Since(app.lastReport) >= time.Second {
+			app.lastReport = time.Now()
+			app.report("hashing %s", app.hashStatus())
+		}
 
 	case fs.ArchiveHashed:
+		app.scanIndex = nil
+		app.scanSeen = nil
 		app.state = archiveReady
+		app.resetHashProgress()
 		app.analyze()
+		app.report("ready: %d duplicate groups", app.nDuplicates)
+		if app.quitting {
+			return m, tea.Quit
+		}
+
+	case command:
+		return m, app.runCommand(msg)
 	}
 	return m, nil
 }
 
+// handleAction runs an action on the current screen.
+func (app *app) handleAction(a action) tea.Cmd {
+	if a == actionQuit {
+		return tea.Quit
+	}
+	if app.help {
+		app.handleHelpAction(a)
+		return nil
+	}
+	if a == actionHelp {
+		app.toggleHelp()
+		return nil
+	}
+	switch app.screen {
+	case screenGroups:
+		app.handleGroupsAction(a)
+		return nil
+	case screenGroup:
+		app.handleGroupAction(a)
+		return nil
+	case screenOverlaps:
+		app.handleOverlapsAction(a)
+		return nil
+	case screenOverlap:
+		app.handleOverlapAction(a)
+		return nil
+	case screenResults:
+		app.handleResultsAction(a)
+		return nil
+	}
+	if app.panel.focused {
+		app.handlePanelAction(a)
+		return nil
+	}
+
+	switch a {
+	case actionCancel:
+		if app.search != "" {
+			app.setSearch("")
+			break
+		}
+		return tea.Quit
+	case actionUp:
+		app.curFolder.selectedIdx--
+
+	case actionDown:
+		app.curFolder.selectedIdx++
+
+	case actionPageUp:
+		app.curFolder.selectedIdx -= app.folderHeight()
+		app.curFolder.offsetIdx -= app.folderHeight()
+
+	case actionPageDown:
+		app.curFolder.selectedIdx += app.folderHeight()
+		app.curFolder.offsetIdx += app.folderHeight()
+
+	case actionHome:
+		app.curFolder.selectedIdx = 0
+		app.curFolder.offsetIdx = 0
+
+	case actionEnd:
+		app.curFolder.selectedIdx = len(app.children(app.curFolder)) - 1
+		app.curFolder.offsetIdx = len(app.children(app.curFolder)) - app.folderHeight()
+
+	case actionBack:
+		if app.curFolder.parent != nil {
+			app.curFolder = app.curFolder.parent
+		}
+	case actionOpen:
+		child := app.selectedFile()
+		if child != nil && child.folder != nil {
+			app.curFolder = child
+			break
+		}
+
+	case actionNextCopy:
+		file := app.selectedFile()
+		if file == nil {
+			break
+		}
+		files := app.copiesOf(file)
+		if len(files) < 2 {
+			break
+		}
+		i := 0
+		for ; files[i] != file; i++ {
+		}
+		nextFile := files[(i+1)%len(files)]
+		app.curFolder = nextFile.parent
+		app.selectFile(nextFile)
+	case actionKeep:
+		if file := app.selectedFile(); file != nil {
+			app.confirmKeep(file)
+		}
+
+	case actionRescan:
+		app.rescan()
+
+	case actionPause:
+		app.setPaused(!app.paused)
+
+	case actionGroups:
+		app.openGroups()
+
+	case actionDupsOnly:
+		app.toggleDupsOnly()
+
+	case actionNextDup:
+		app.nextDuplicate(false)
+
+	case actionPrevDup:
+		app.nextDuplicate(true)
+
+	case actionCopies:
+		app.focusPanel()
+
+	case actionOverlaps:
+		app.openOverlaps()
+
+	case actionMark:
+		if file := app.selectedFile(); file != nil {
+			app.toggleMark(file)
+			app.curFolder.selectedIdx++
+		}
+
+	case actionMarkDups:
+		app.markDuplicates()
+
+	case actionInvertMarks:
+		app.invertMarks()
+
+	case actionClearMarks:
+		app.clearMarks()
+
+	case actionDelete:
+		app.runBatch(batchDelete)
+
+	case actionTrash:
+		app.runBatch(batchTrash)
+
+	case actionLink:
+		app.runBatch(batchLink)
+
+	case actionExport:
+		app.runBatch(batchExport)
+
+	case actionSearch:
+		app.openPrompt(promptSearch)
+
+	case actionFilter:
+		app.openPrompt(promptFilter)
+
+	case actionFind:
+		app.openPrompt(promptFind)
+	}
+	return nil
+}
+
+func (app *app) isDoubleClick(msg tea.MouseMsg) bool {
+	result := app.lastX == msg.X && app.lastY == msg.Y &&
+		time.Since(app.lastClickTime).Milliseconds() < 500
+	app.lastClickTime = time.Now()
+	app.lastX = msg.X
+	app.lastY = msg.Y
+	return result
+}
+
+func (app *app) scroll(delta int) {
+	switch app.screen {
+	case screenGroups:
+		app.groupList.offsetIdx += delta
+	case screenGroup:
+		app.members.offsetIdx += delta
+	case screenOverlaps:
+		app.overlaps.offsetIdx += delta
+	case screenOverlap:
+		app.diff.offsetIdx += delta
+	case screenResults:
+		app.resultCursor.offsetIdx += delta
+	default:
+		app.curFolder.offsetIdx += delta
+	}
+}
+
 func (m model) View() string {
 	app := <-m
 	result := app.render()
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"dedup/fs"
+)
+
+// columnDef describes a column of the folder view. The name column is
+// always shown first and takes the width left over by the other columns.
+type columnDef struct {
+	column    sortColumn
+	name      string
+	title     string
+	width     int
+	right     bool
+	ascending bool
+	// volatile columns change without the folder contents changing,
+	// so folders sorted by them are re-sorted on every flush.
+	volatile bool
+	value    func(f *file) string
+	compare  func(a, b *file) int
+}
+
+const (
+	minNameWidth = 20
+	shortHashLen = 8
+)
+
+var defaultColumns = []string{"modified", "size"}
+
+// columns is indexed by sortColumn.
+var columns = []columnDef{
+	{
+		column: sortByName, name: "name", title: "Document", ascending: true,
+		compare: func(a, b *file) int {
+			return cmp.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
+		},
+	},
+	{
+		column: sortByTime, name: "modified", title: "Date Modified", width: 20, ascending: true,
+		value: func(f *file) string {
+			return f.modTime.Format("2006-01-02 15:04:05")
+		},
+		compare: func(a, b *file) int {
+			return a.modTime.Compare(b.modTime)
+		},
+	},
+	{
+		column: sortBySize, name: "size", title: "Size", width: 20, right: true, ascending: true,
+		value: func(f *file) string {
+			return formatSize(f.size)
+		},
+		compare: func(a, b *file) int {
+			return cmp.Compare(a.size, b.size)
+		},
+	},
+	{
+		column: sortByHash, name: "hash", title: "Hash", width: shortHashLen + 2, ascending: true, volatile: true,
+		value: func(f *file) string {
+			return f.hash[:min(len(f.hash), shortHashLen)]
+		},
+		compare: func(a, b *file) int {
+			return cmp.Compare(a.hash, b.hash)
+		},
+	},
+	{
+		column: sortByDups, name: "dups", title: "Dups", width: 7, right: true, volatile: true,
+		value: func(f *file) string {
+			if f.folder != nil || f.dups == 0 {
+				return ""
+			}
+			return fmt.Sprint(f.dups)
+		},
+		compare: func(a, b *file) int {
+			return cmp.Compare(a.dups, b.dups)
+		},
+	},
+	{
+		column: sortByWasted, name: "wasted", title: "Wasted", width: 11, right: true, volatile: true,
+		value: func(f *file) string {
+			if f.wastedSize() == 0 {
+				return ""
+			}
+			return formatBytes(f.wastedSize())
+		},
+		compare: func(a, b *file) int {
+			return cmp.Compare(a.wastedSize(), b.wastedSize())
+		},
+	},
+	{
+		column: sortByExt, name: "ext", title: "Ext", width: 7, ascending: true,
+		value: extension,
+		compare: func(a, b *file) int {
+			return cmp.Compare(extension(a), extension(b))
+		},
+	},
+	{
+		column: sortByInode, name: "inode", title: "Inode", width: 12, right: true, ascending: true,
+		value: func(f *file) string {
+			if f.inode == 0 {
+				return ""
+			}
+			return fmt.Sprint(f.inode)
+		},
+		compare: func(a, b *file) int {
+			return cmp.Compare(a.inode, b.inode)
+		},
+	},
+	{
+		column: sortByStatus, name: "status", title: "Status", width: 10, ascending: true, volatile: true,
+		value: hashStatus,
+		compare: func(a, b *file) int {
+			return cmp.Compare(hashStatus(a), hashStatus(b))
+		},
+	},
+	{
+		column: sortByItems, name: "items", title: "Items", width: 8, right: true,
+		value: func(f *file) string {
+			if f.folder == nil {
+				return ""
+			}
+			return formatCount(len(f.children))
+		},
+		compare: func(a, b *file) int {
+			return cmp.Compare(itemCount(a), itemCount(b))
+		},
+	},
+}
+
+// newColumns looks up the configured columns by name, in order.
+// The name column is implied.
+func newColumns(names []string) ([]*columnDef, error) {
+	if names == nil {
+		names = defaultColumns
+	}
+	var result []*columnDef
+	for _, name := range names {
+		idx := findColumn(name)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if idx == int(sortByName) {
+			continue
+		}
+		result = append(result, &columns[idx])
+	}
+	return result, nil
+}
+
+func findColumn(name string) int {
+	for idx, def := range columns {
+		if def.name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// newSortOrder returns the initial sort direction of every column.
+func newSortOrder() []bool {
+	order := make([]bool, len(columns))
+	for idx, def := range columns {
+		order[idx] = def.ascending
+	}
+	return order
+}
+
+// folderColumns returns the configured columns that fit the screen, dropping
+// the last ones first, and the width left for names.
+func (app *app) folderColumns() ([]*columnDef, int) {
+	shown := app.columns
+	for {
+		nameWidth := app.screenWidth - 5
+		for _, def := range shown {
+			nameWidth -= def.width
+		}
+		if nameWidth >= minNameWidth || len(shown) == 0 {
+			return shown, nameWidth
+		}
+		shown = shown[:len(shown)-1]
+	}
+}
+
+// cell pads text to the column width with a space on the outer side.
+func (def *columnDef) cell(text string) string {
+	if def.right {
+		return padLeft(text, def.width-1) + " "
+	}
+	return " " + padRight(text, def.width-1)
+}
+
+func (f *file) wastedSize() int {
+	if f.folder != nil {
+		return f.wasted
+	}
+	if f.dups == 0 {
+		return 0
+	}
+	// Each copy carries its share of the space the extra copies take,
+	// so that the whole group adds up to the size of all copies but one.
+	return f.size * (f.dups - 1) / f.dups
+}
+
+func extension(f *file) string {
+	if f.folder != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(f.name), "."))
+}
+
+// hashStatus tells whether the hash of a file is still pending, covers its
+// whole content or only samples the head and tail of a large file.
+func hashStatus(f *file) string {
+	switch {
+	case f.folder != nil:
+		return ""
+	case f.hash == "":
+		return "pending"
+	case f.size > fs.FullHashSize:
+		return "sampled"
+	default:
+		return "verified"
+	}
+}
+
+func itemCount(f *file) int {
+	if f.folder == nil {
+		return 0
+	}
+	return len(f.children)
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"slices"
+	"testing"
+
+	"dedup/fs"
+)
+
+func TestColumns(t *testing.T) {
+	if _, err := newColumns([]string{"size", "colour"}); err == nil {
+		t.Error("unknown column accepted")
+	}
+
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 300, Hash: "h1"},
+		fs.FileMeta{Path: "a/y", Size: 300, Hash: "h1"},
+		fs.FileMeta{Path: "b", Size: 300, Hash: "h1"},
+		fs.FileMeta{Path: "c", Size: 500, Hash: "h2"},
+	)
+	app.columns, _ = newColumns([]string{"name", "wasted", "size", "hash", "inode", "modified"})
+
+	a := app.findFile([]string{"a"})
+	if a.wastedSize() != 400 {
+		t.Errorf("folder wasted = %d, want 400", a.wastedSize())
+	}
+	if wasted := app.rootFolder.wastedSize(); wasted != 600 {
+		t.Errorf("archive wasted = %d, want the 600 bytes of the extra copies", wasted)
+	}
+
+	app.screenWidth = 80
+	shown, nameWidth := app.folderColumns()
+	if len(shown) != 4 || nameWidth != 22 {
+		t.Errorf("80 columns: %d columns, name width %d", len(shown), nameWidth)
+	}
+	app.screenWidth = 200
+	if shown, _ := app.folderColumns(); len(shown) != 5 {
+		t.Errorf("200 columns: %d columns", len(shown))
+	}
+
+	app.rootFolder.sortColumn = sortByWasted
+	app.rootFolder.sort()
+	if got := app.names(app.rootFolder); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("sorted by wasted: %v", got)
+	}
+}
+
+func TestHashStatus(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "small", Size: fs.FullHashSize, Hash: "h1"},
+		fs.FileMeta{Path: "large", Size: fs.FullHashSize + 1, Hash: "h2"},
+		fs.FileMeta{Path: "new", Size: 1},
+	)
+	for name, want := range map[string]string{"small": "verified", "large": "sampled", "new": "pending"} {
+		if got := hashStatus(app.findFile([]string{name})); got != want {
+			t.Errorf("%s: status %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// confirmation is a pending destructive action shown in a modal dialog
+// until the user accepts or cancels it.
+type confirmation struct {
+	title  string
+	paths  []string
+	size   int
+	action func()
+	cursor
+}
+
+// confirm runs action after the user accepted removing files,
+// or right away when confirmations are disabled.
+func (app *app) confirm(title string, targets files, action func()) {
+	if app.readOnly {
+		app.message = "Archive is opened read-only"
+		return
+	}
+	if app.skipConfirm {
+		action()
+		return
+	}
+	c := &confirmation{title: title, action: action}
+	var removed files
+	for _, f := range targets {
+		f.collectTree(&removed)
+	}
+	for _, f := range removed {
+		if app.isProtected(f) {
+			continue
+		}
+		c.paths = append(c.paths, f.pathString())
+		c.size += f.size
+	}
+	slices.Sort(c.paths)
+	app.confirmation = c
+}
+
+// confirmKeep asks to remove every other copy of keep.
+func (app *app) confirmKeep(keep *file) {
+	if app.folderBusy(keep) {
+		return
+	}
+	copies := app.copiesOf(keep)
+	if len(copies) < 2 {
+		return
+	}
+	var removed files
+	for _, dup := range copies {
+		if dup != keep && isCopy(dup, keep) {
+			app.collectCopies(dup, keep, &removed)
+		}
+	}
+	if len(removed) == 0 {
+		app.message = "No copies left to remove"
+		return
+	}
+	app.confirm(fmt.Sprintf("Keep %s and remove its other copies?", keep.pathString()), removed, func() {
+		app.keepFile(keep)
+		if app.screen == screenFolder {
+			app.selectFile(keep)
+		}
+	})
+}
+
+func (app *app) confirmationHeight() int {
+	return app.screenHeight - 4
+}
+
+func (app *app) handleConfirmKey(key string) {
+	a, ok := app.dialogKeys[key]
+	if !ok {
+		a = app.keys[key]
+	}
+	c := app.confirmation
+	if c.handleAction(a, len(c.paths), app.confirmationHeight()) {
+		return
+	}
+	switch a {
+	case actionConfirm:
+		app.confirmation = nil
+		c.action()
+	case actionDeny:
+		app.confirmation = nil
+		app.message = "Cancelled"
+	}
+}
+
+func (b *builder) renderConfirmation() {
+	c := b.app.confirmation
+	height := b.app.confirmationHeight()
+	c.clamp(len(c.paths), height)
+
+	b.setStyle(b.theme.breadcrumbs)
+	b.text(" " + c.title)
+	b.newLine()
+
+	b.setStyle(b.theme.folderHeader)
+	b.text(fmt.Sprintf(" %d files, %s will be freed", len(c.paths), formatBytes(c.size)))
+	b.newLine()
+
+	for i := range height {
+		idx := i + c.offsetIdx
+		b.setRowStyle(idx == c.selectedIdx, true, false)
+		if idx < len(c.paths) {
+			b.text("  " + c.paths[idx])
+		}
+		b.newLine()
+	}
+
+	b.setStyle(b.theme.archive)
+	b.text(fmt.Sprintf(" %s: confirm   %s: cancel",
+		strings.Join(b.app.dialogKeys.keysOf(actionConfirm), "/"), strings.Join(b.app.dialogKeys.keysOf(actionDeny), "/")))
+	b.text(padRight("", b.app.screenWidth-b.x))
+}
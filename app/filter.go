@@ -0,0 +1,356 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"dedup/config"
+)
+
+const maxSearchResults = 10000
+
+type (
+	// filter hides the files that don't match its predicates in every folder;
+	// folders stay visible while they hold a matching file.
+	filter struct {
+		text     string
+		glob     string
+		ext      string
+		minSize  int
+		maxSize  int
+		dupsOnly bool
+	}
+
+	prompt struct {
+		kind promptKind
+		text string
+		err  string
+	}
+
+	promptKind int
+
+	selectResult struct {
+		idx int
+	}
+)
+
+const (
+	promptSearch promptKind = iota
+	promptFind
+	promptFilter
+)
+
+// parseFilter parses space separated predicates: a name glob such as "*.jpg",
+// "ext:jpg", "size>10M", "size<1G" and "dups".
+func parseFilter(text string) (filter, error) {
+	result := filter{text: strings.TrimSpace(text)}
+	for _, token := range strings.Fields(strings.ToLower(text)) {
+		var err error
+		switch {
+		case token == "dups":
+			result.dupsOnly = true
+		case strings.HasPrefix(token, "ext:"):
+			result.ext = "." + strings.TrimPrefix(token[len("ext:"):], ".")
+		case strings.HasPrefix(token, "size>"):
+			result.minSize, err = config.ParseSize(token[len("size>"):])
+		case strings.HasPrefix(token, "size<"):
+			result.maxSize, err = config.ParseSize(token[len("size<"):])
+		default:
+			result.glob = strings.TrimPrefix(token, "name:")
+			_, err = filepath.Match(result.glob, "")
+		}
+		if err != nil {
+			return filter{}, fmt.Errorf("%q: %w", token, err)
+		}
+	}
+	return result, nil
+}
+
+func (f *filter) active() bool {
+	return f.text != ""
+}
+
+func (f *filter) matches(file *file) bool {
+	if f.dupsOnly && file.dups == 0 {
+		return false
+	}
+	name := strings.ToLower(file.name)
+	if f.ext != "" && strings.ToLower(filepath.Ext(name)) != f.ext {
+		return false
+	}
+	if f.glob != "" {
+		if ok, _ := filepath.Match(f.glob, name); !ok {
+			return false
+		}
+	}
+	if file.size < f.minSize || f.maxSize > 0 && file.size > f.maxSize {
+		return false
+	}
+	return true
+}
+
+// matchesName reports whether name contains text, or matches it when text is a glob.
+func matchesName(name, text string) bool {
+	name, text = strings.ToLower(name), strings.ToLower(text)
+	if strings.ContainsAny(text, "*?[") {
+		ok, _ := filepath.Match(text, name)
+		return ok
+	}
+	return strings.Contains(name, text)
+}
+
+// children returns the children of folder shown with the current filter
+// and search. Cursor indices of folders refer to this list.
+func (app *app) children(folder *file) files {
+	searching := app.search != "" && app.searchFolder == folder
+	if !app.filtering() && !searching {
+		return folder.children
+	}
+	if visible, ok := app.visible[folder]; ok {
+		return visible
+	}
+	visible := files{}
+	for _, child := range folder.children {
+		if app.isVisible(child) && (!searching || matchesName(child.name, app.search)) {
+			visible = append(visible, child)
+		}
+	}
+	if app.dupsOnly {
+		compare := columns[folder.sortColumn].compare
+		visible.sortBy(func(a, b *file) int {
+			return compare(app.shownFile(a), app.shownFile(b))
+		})
+		if !folder.sortAscending[folder.sortColumn] {
+			visible.reverse()
+		}
+	}
+	app.visible[folder] = visible
+	return visible
+}
+
+// shownFile returns f with the aggregates shown in the tree: with duplicates
+// only, the size, time and wasted space of a folder cover just the duplicates below it.
+func (app *app) shownFile(f *file) *file {
+	if !app.dupsOnly || f.folder == nil {
+		return f
+	}
+	if shown, ok := app.shown[f]; ok {
+		return shown
+	}
+	shown := *f
+	folder := *f.folder
+	shown.folder = &folder
+	shown.size, shown.modTime, shown.dups, shown.wasted = 0, time.Time{}, 0, 0
+	for _, child := range app.children(f) {
+		shown.updateMeta(app.shownFile(child))
+	}
+	app.shown[f] = &shown
+	return &shown
+}
+
+func (app *app) filtering() bool {
+	return app.filter.active() || app.dupsOnly
+}
+
+func (app *app) isVisible(f *file) bool {
+	if !app.filtering() {
+		return true
+	}
+	if app.dupsOnly && f.dups == 0 {
+		return false
+	}
+	if !app.filter.active() {
+		return true
+	}
+	if f.folder == nil {
+		return app.filter.matches(f)
+	}
+	if app.filter.dupsOnly && f.dups == 0 {
+		return false
+	}
+	if visible, ok := app.matching[f]; ok {
+		return visible
+	}
+	visible := false
+	for _, child := range f.children {
+		if app.isVisible(child) {
+			visible = true
+			break
+		}
+	}
+	app.matching[f] = visible
+	return visible
+}
+
+// invalidateView drops the cached visible children after the tree, the filter or the search changed.
+func (app *app) invalidateView() {
+	clear(app.visible)
+	clear(app.matching)
+	clear(app.shown)
+}
+
+func (app *app) setFilter(filter filter) {
+	selected := app.selectedFile()
+	app.filter = filter
+	app.invalidateView()
+	app.selectFile(selected)
+}
+
+// toggleDupsOnly switches the tree between all files and only the paths leading to duplicates.
+func (app *app) toggleDupsOnly() {
+	selected := app.selectedFile()
+	app.dupsOnly = !app.dupsOnly
+	app.invalidateView()
+	for app.curFolder != app.rootFolder && !app.isVisible(app.curFolder) {
+		selected = app.curFolder
+		app.curFolder = app.curFolder.parent
+	}
+	app.selectFile(selected)
+}
+
+func (app *app) setSearch(text string) {
+	selected := app.selectedFile()
+	app.search = text
+	app.searchFolder = app.curFolder
+	app.invalidateView()
+	app.selectFile(selected)
+}
+
+func (app *app) openPrompt(kind promptKind) {
+	text := ""
+	switch kind {
+	case promptSearch:
+		app.setSearch("")
+	case promptFilter:
+		text = app.filter.text
+	}
+	app.prompt = &prompt{kind: kind, text: text}
+}
+
+func (app *app) handlePromptKey(msg tea.KeyMsg) {
+	p := app.prompt
+	switch msg.Type {
+	case tea.KeyEsc:
+		if p.kind == promptSearch {
+			app.setSearch("")
+		}
+		app.prompt = nil
+		return
+	case tea.KeyEnter:
+		app.submitPrompt()
+		return
+	case tea.KeyBackspace:
+		runes := []rune(p.text)
+		if len(runes) > 0 {
+			p.text = string(runes[:len(runes)-1])
+		}
+	case tea.KeySpace:
+		p.text += " "
+	case tea.KeyRunes:
+		p.text += string(msg.Runes)
+	default:
+		return
+	}
+	p.err = ""
+	if p.kind == promptSearch {
+		app.setSearch(p.text)
+	}
+}
+
+func (app *app) submitPrompt() {
+	p := app.prompt
+	switch p.kind {
+	case promptSearch:
+		app.setSearch(p.text)
+	case promptFilter:
+		filter, err := parseFilter(p.text)
+		if err != nil {
+			p.err = err.Error()
+			return
+		}
+		app.setFilter(filter)
+	case promptFind:
+		if p.text == "" {
+			break
+		}
+		app.find(p.text)
+	}
+	app.prompt = nil
+}
+
+// find searches the whole archive for files and folders by name.
+func (app *app) find(text string) {
+	app.findText = text
+	app.results = app.results[:0]
+	var walk func(folder *file)
+	walk = func(folder *file) {
+		for _, child := range folder.children {
+			if len(app.results) >= maxSearchResults {
+				return
+			}
+			if matchesName(child.name, text) && app.isVisible(child) {
+				app.results = append(app.results, child)
+			}
+			if child.folder != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(app.rootFolder)
+	app.resultCursor = cursor{}
+	app.screen = screenResults
+}
+
+func (app *app) handleResultsAction(a action) {
+	if app.resultCursor.handleAction(a, len(app.results), app.screenHeight-4) {
+		return
+	}
+	switch a {
+	case actionCancel, actionBack:
+		app.screen = screenFolder
+	case actionFind:
+		app.openPrompt(promptFind)
+	case actionOpen, actionKeep:
+		if app.resultCursor.selectedIdx < len(app.results) {
+			app.revealFile(app.results[app.resultCursor.selectedIdx])
+		}
+	}
+}
+
+func (b *builder) renderResults() {
+	b.setStyle(b.theme.breadcrumbs)
+	text := fmt.Sprintf(" Found %d for %q", len(b.app.results), b.app.findText)
+	if len(b.app.results) >= maxSearchResults {
+		text += " (showing first results only)"
+	}
+	b.text(text)
+	b.newLine()
+
+	b.setStyle(b.theme.folderHeader)
+	b.text(padRight("  Path", b.app.screenWidth-39))
+	b.text(padRight("Date Modified", 20))
+	b.text(padLeft("Size", 18))
+	b.newLine()
+
+	b.app.resultCursor.clamp(len(b.app.results), b.app.screenHeight-4)
+	b.renderFileRows(b.app.results, &b.app.resultCursor, b.app.screenHeight-4, true, nil,
+		func(idx int) any { return selectResult{idx: idx} })
+}
+
+func (b *builder) renderPrompt() {
+	b.setStyle(b.theme.archive)
+	label := [...]string{" Search: ", " Find: ", " Filter: "}[b.app.prompt.kind]
+	b.text(label)
+	b.text(b.app.prompt.text)
+	b.text("█")
+	if b.app.prompt.err != "" {
+		b.text("  " + b.app.prompt.err)
+	} else if b.app.prompt.kind == promptFilter {
+		b.text("  e.g. *.jpg ext:raw size>10M size<1G dups")
+	}
+	b.text(padRight("", b.app.screenWidth-b.x))
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"dedup/fs"
+)
+
+func TestFilter(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "photos/a.jpg", Size: 10 << 20, Hash: "h1"},
+		fs.FileMeta{Path: "photos/b.JPG", Size: 1 << 10, Hash: "h2"},
+		fs.FileMeta{Path: "docs/c.txt", Size: 20 << 20, Hash: "h3"},
+		fs.FileMeta{Path: "backup/a.jpg", Size: 10 << 20, Hash: "h1"},
+	)
+
+	jpegs, err := parseFilter("*.jpg size>1M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.setFilter(jpegs)
+	if got := app.names(app.rootFolder); !slices.Equal(got, []string{"backup", "photos"}) {
+		t.Errorf("root with %q: %v", jpegs.text, got)
+	}
+	if got := app.names(app.findFile([]string{"photos"})); !slices.Equal(got, []string{"a.jpg"}) {
+		t.Errorf("photos with %q: %v", jpegs.text, got)
+	}
+
+	dups, _ := parseFilter("dups")
+	app.setFilter(dups)
+	app.curFolder = app.findFile([]string{"photos"})
+	app.setSearch("b")
+	if got := app.names(app.curFolder); len(got) != 0 {
+		t.Errorf("search within duplicates only: %v", got)
+	}
+	app.setFilter(filter{})
+	if got := app.names(app.curFolder); !slices.Equal(got, []string{"b.JPG"}) {
+		t.Errorf("search without filter: %v", got)
+	}
+
+	app.curFolder = app.findFile([]string{"docs"})
+	app.toggleDupsOnly()
+	if got := app.names(app.rootFolder); app.curFolder != app.rootFolder || !slices.Equal(got, []string{"backup", "photos"}) {
+		t.Errorf("duplicates only: %v in %v", got, app.curFolder)
+	}
+	app.toggleDupsOnly()
+
+	app.find("a.jpg")
+	if len(app.results) != 2 || app.screen != screenResults {
+		t.Errorf("expected 2 results, got %v", app.results)
+	}
+
+	if _, err := parseFilter("size>lots"); err == nil {
+		t.Error("invalid size accepted")
+	}
+}
+
+func TestDupsOnlyAggregates(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "photos/a.jpg", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "photos/big.raw", Size: 1000, ModTime: testTime.Add(time.Hour), Hash: "h2"},
+		fs.FileMeta{Path: "backup/a.jpg", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "backup/b.jpg", Size: 20, Hash: "h3"},
+		fs.FileMeta{Path: "other/b.jpg", Size: 20, Hash: "h3"},
+	)
+	app.rootFolder.sortColumn = sortBySize
+	app.rootFolder.sort()
+	app.toggleDupsOnly()
+
+	photos := app.shownFile(app.findFile([]string{"photos"}))
+	wasted := app.findFile([]string{"photos", "a.jpg"}).wastedSize()
+	if photos.size != 10 || !photos.modTime.Equal(testTime) || photos.wastedSize() != wasted {
+		t.Errorf("photos with duplicates only: size %d, time %v, wasted %d", photos.size, photos.modTime, photos.wastedSize())
+	}
+	if got := app.names(app.rootFolder); !slices.Equal(got, []string{"photos", "other", "backup"}) {
+		t.Errorf("sorted by size with duplicates only: %v", got)
+	}
+
+	app.toggleDupsOnly()
+	if got := app.names(app.rootFolder); !slices.Equal(got, []string{"other", "backup", "photos"}) {
+		t.Errorf("sorted by size: %v", got)
+	}
+}
+
+func TestFindDropsRemovedFiles(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/c/x", Size: 1, Hash: "h1"},
+	)
+	app.screenHeight = 30
+	app.find("x")
+	if len(app.results) != 2 {
+		t.Fatalf("found %v", app.results)
+	}
+	app.resultCursor.selectedIdx = 1
+
+	app.keepFile(app.findFile([]string{"a", "x"}))
+	if len(app.results) != 1 || app.results[0].pathString() != "a/x" {
+		t.Fatalf("results after removing b/c/x: %v", app.results)
+	}
+	app.handleResultsAction(actionOpen)
+	app.resultCursor.selectedIdx = 0
+	app.handleResultsAction(actionOpen)
+	if app.screen != screenFolder || app.curFolder != app.findFile([]string{"a"}) {
+		t.Errorf("revealed %v", app.curFolder.pathString())
+	}
+}
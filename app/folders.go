@@ -0,0 +1,177 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"slices"
+	"strings"
+)
+
+const folderKeyPrefix = "folder:"
+
+// signFolders recomputes the signatures of the folders in app.unsigned,
+// deepest first, moving up to a parent only when a signature changed.
+// The root folder is never signed.
+func (app *app) signFolders() {
+	levels := map[int]map[*file]struct{}{}
+	deepest := 0
+	add := func(folder *file, depth int) {
+		if levels[depth] == nil {
+			levels[depth] = map[*file]struct{}{}
+		}
+		levels[depth][folder] = struct{}{}
+		deepest = max(deepest, depth)
+	}
+	for folder := range app.unsigned {
+		add(folder, folder.depth())
+	}
+	clear(app.unsigned)
+
+	for depth := deepest; depth > 0; depth-- {
+		for folder := range levels[depth] {
+			signature := folder.sign(func(child *file) string { return child.signature })
+			if signature != folder.signature {
+				app.setSignature(folder, signature)
+				add(folder.parent, depth-1)
+			}
+		}
+	}
+}
+
+// setSignature moves folder to another entry of app.bySignature, scheduling
+// the groups that may have changed for analyzeFolders.
+func (app *app) setSignature(folder *file, signature string) {
+	if old := folder.signature; old != "" {
+		group := app.bySignature[old]
+		if idx := slices.Index(group, folder); idx >= 0 {
+			group = slices.Delete(slices.Clone(group), idx, idx+1)
+		}
+		if len(group) == 0 {
+			delete(app.bySignature, old)
+		} else {
+			app.bySignature[old] = group
+		}
+		app.regroup[old] = struct{}{}
+	}
+	folder.signature = signature
+	if signature != "" {
+		app.bySignature[signature] = append(app.bySignature[signature], folder)
+		app.regroup[signature] = struct{}{}
+	}
+	// Whether the subfolders are reported on their own depends on folder.
+	app.regroupChildren(folder)
+}
+
+func (app *app) regroupChildren(folder *file) {
+	for _, child := range folder.children {
+		if child.folder != nil && child.signature != "" {
+			app.regroup[child.signature] = struct{}{}
+		}
+	}
+}
+
+// unsignTree drops f and every folder below it from the signature index
+// once they are detached from the tree.
+func (app *app) unsignTree(f *file) {
+	if f.folder == nil {
+		return
+	}
+	delete(app.unsigned, f)
+	if f.signature != "" {
+		app.setSignature(f, "")
+	}
+	for _, child := range f.children {
+		app.unsignTree(child)
+	}
+}
+
+// contentSignature computes the signature of folder from the current tree,
+// ignoring the signatures stored by the last analysis.
+func (folder *file) contentSignature() string {
+	return folder.sign((*file).contentSignature)
+}
+
+// sign computes a folder signature from the sorted names and hashes of its
+// files and the signatures of its folders, which subfolder returns for every
+// subfolder. Folders holding unhashed files get no signature.
+func (folder *file) sign(subfolder func(child *file) string) string {
+	entries := make([]string, 0, len(folder.children))
+	complete := true
+	for _, child := range folder.children {
+		signature := child.hash
+		if child.folder != nil {
+			signature = subfolder(child)
+		}
+		if signature == "" {
+			complete = false
+			continue
+		}
+		entries = append(entries, child.name+"\x00"+signature)
+	}
+	if !complete || len(entries) == 0 {
+		return ""
+	}
+	slices.Sort(entries)
+	hash := sha256.New()
+	for _, entry := range entries {
+		hash.Write([]byte(entry))
+		hash.Write([]byte{'\n'})
+	}
+	return folderKeyPrefix + base64.RawURLEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// analyzeFolders finds folders that are complete copies of each other,
+// revisiting only the groups changed since the last analysis.
+// Copies nested inside other identical folders are reported through their parents.
+func (app *app) analyzeFolders() {
+	app.signFolders()
+	for signature := range app.regroup {
+		// Resizing a group changes whether the folders below it are nested copies.
+		for _, folder := range app.bySignature[signature] {
+			app.regroupChildren(folder)
+		}
+	}
+	for signature := range app.regroup {
+		folders := app.bySignature[signature]
+		if len(folders) > 1 && !isNestedCopy(folders, app.bySignature) {
+			app.byFolder[signature] = folders
+		} else {
+			delete(app.byFolder, signature)
+		}
+	}
+	clear(app.regroup)
+	app.nFolderDups = len(app.byFolder)
+}
+
+func isNestedCopy(folders []*file, bySignature map[string][]*file) bool {
+	for _, folder := range folders {
+		parent := folder.parent
+		if parent.signature == "" || len(bySignature[parent.signature]) < 2 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *file) copiesKey() string {
+	if f.folder != nil {
+		return f.signature
+	}
+	return f.hash
+}
+
+func (app *app) groupMembers(key string) []*file {
+	if strings.HasPrefix(key, folderKeyPrefix) {
+		return app.byFolder[key]
+	}
+	return app.byHash[key]
+}
+
+// copiesOf returns every copy of a file or an identical folder, including f itself.
+func (app *app) copiesOf(f *file) []*file {
+	key := f.copiesKey()
+	if key == "" {
+		return nil
+	}
+	return app.groupMembers(key)
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"dedup/fs"
+)
+
+func TestIdenticalFolders(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "photos/2019/a.jpg", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "photos/2019/raw/b.raw", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "backup/2019-copy/a.jpg", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "backup/2019-copy/raw/b.raw", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "other/a.jpg", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "other/c.txt", Size: 3, Hash: "h3"},
+	)
+
+	if app.nFolderDups != 1 {
+		t.Fatalf("expected one identical folder group, got %d", app.nFolderDups)
+	}
+	original := app.findFile([]string{"photos", "2019"})
+	if copies := app.copiesOf(original); len(copies) != 2 {
+		t.Fatalf("expected 2 copies of %v, got %v", original, copies)
+	}
+	if copies := app.copiesOf(app.findFile([]string{"photos", "2019", "raw"})); len(copies) != 0 {
+		t.Errorf("nested identical folder reported on its own: %v", copies)
+	}
+
+	app.keepFile(original)
+	if app.findFile([]string{"backup"}) != nil {
+		t.Error("identical folder was not removed")
+	}
+	if app.findFile([]string{"other", "a.jpg"}) == nil {
+		t.Error("file outside the identical folders was removed")
+	}
+}
+
+func TestKeepFolderRefusesChangedCopies(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "a/y", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/y", Size: 2, Hash: "h2"},
+	)
+	a := app.findFile([]string{"a"})
+	if len(app.copiesOf(a)) != 2 {
+		t.Fatalf("expected a and b to be identical, got %v", app.copiesOf(a))
+	}
+
+	app.mergeMetas(fs.FileMetas{{Path: "b/x", Size: 999, ModTime: testTime}})
+	app.state = archiveHashing
+	app.keepFile(a)
+	if app.findFile([]string{"b", "x"}) == nil || app.message == "" {
+		t.Fatal("identical folder resolved while hashing")
+	}
+
+	app.mergeMetas(fs.FileMetas{{Path: "b/x", Size: 999, ModTime: testTime, Hash: "h9"}})
+	app.state = archiveReady
+	app.keepFile(a)
+	if app.findFile([]string{"b", "x"}) == nil || app.findFile([]string{"b", "y"}) == nil {
+		t.Error("changed folder was removed as a copy")
+	}
+}
+
+func TestIncrementalFolderIndex(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "a/sub/y", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/sub/y", Size: 2, Hash: "h2"},
+	)
+	a, sub := app.findFile([]string{"a"}), app.findFile([]string{"a", "sub"})
+	if app.nFolderDups != 1 || len(app.copiesOf(a)) != 2 || len(app.copiesOf(sub)) != 0 {
+		t.Fatalf("expected a and b to be identical: %d groups", app.nFolderDups)
+	}
+
+	app.mergeMetas(fs.FileMetas{{Path: "b/z", Size: 3, ModTime: testTime}})
+	app.analyze()
+	if app.nFolderDups != 1 || len(app.copiesOf(a)) != 0 || len(app.copiesOf(sub)) != 2 {
+		t.Errorf("after adding b/z: %d groups, a %v, sub %v", app.nFolderDups, app.copiesOf(a), app.copiesOf(sub))
+	}
+
+	app.updateMeta(app.findFile([]string{"b", "z"}), fs.FileMeta{Path: "b/z", Size: 3, ModTime: testTime, Hash: "h3"})
+	app.addMeta(fs.FileMeta{Path: "a/z", Size: 3, ModTime: testTime, Hash: "h3"})
+	app.analyze()
+	if app.nFolderDups != 1 || len(app.copiesOf(a)) != 2 || len(app.copiesOf(sub)) != 0 {
+		t.Errorf("after hashing b/z: %d groups, a %v, sub %v", app.nFolderDups, app.copiesOf(a), app.copiesOf(sub))
+	}
+
+	app.removeFile(app.findFile([]string{"b"}))
+	app.analyze()
+	if app.nFolderDups != 0 || len(app.bySignature[a.signature]) != 1 || len(app.bySignature[sub.signature]) != 1 {
+		t.Errorf("removed folder still indexed: %d groups, %v", app.nFolderDups, app.bySignature)
+	}
+}
+
+func TestConfirmKeepFolderListsCopies(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "a/y", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/y", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "c/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "c/y", Size: 2, Hash: "h2"},
+	)
+	app.mergeMetas(fs.FileMetas{{Path: "c/x", Size: 9, ModTime: testTime, Hash: "h9"}})
+
+	app.confirmKeep(app.findFile([]string{"a"}))
+	c := app.confirmation
+	if c == nil {
+		t.Fatal("no confirmation")
+	}
+	if !slices.Equal(c.paths, []string{"b/x", "b/y"}) || c.size != 3 {
+		t.Errorf("confirmation lists %v, %d bytes", c.paths, c.size)
+	}
+}
+
+func TestKeepFolderWhileHashingExplains(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "c/y", Size: 2},
+	)
+	app.screenWidth, app.screenHeight = 120, 30
+	app.state = archiveHashing
+	app.selectFile(app.findFile([]string{"a"}))
+	m := make(model, 1)
+	m <- app
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m.Update(fs.FileHashed{Path: "c/y", Hash: "h2"})
+	if view := m.View(); !strings.Contains(view, "once hashing is done") {
+		t.Error("status line does not explain why the folder was not kept")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if view := m.View(); strings.Contains(view, "once hashing is done") {
+		t.Error("message outlived the next key")
+	}
+}
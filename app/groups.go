@@ -0,0 +1,312 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type (
+	groupList struct {
+		groups        []*group
+		sortColumn    groupColumn
+		sortAscending []bool
+		cursor
+	}
+
+	group struct {
+		key   string
+		files files
+	}
+
+	groupColumn int
+
+	selectGroup struct {
+		idx int
+	}
+
+	groupSortCmd struct {
+		column groupColumn
+	}
+
+	selectMember struct {
+		idx   int
+		panel bool
+	}
+)
+
+const (
+	groupByName groupColumn = iota
+	groupByCopies
+	groupBySize
+	groupByWasted
+)
+
+func newGroupList() groupList {
+	return groupList{
+		sortColumn:    groupByWasted,
+		sortAscending: []bool{true, false, false, false},
+	}
+}
+
+func newGroup(key string, members []*file) *group {
+	group := &group{key: key, files: slices.Clone(members)}
+	slices.SortFunc(group.files, func(a, b *file) int {
+		return cmp.Compare(a.pathString(), b.pathString())
+	})
+	return group
+}
+
+func (g *group) size() int {
+	return g.files[0].size
+}
+
+func (g *group) wasted() int {
+	return g.size() * (len(g.files) - 1)
+}
+
+func (g *group) name() string {
+	if g.files[0].folder != nil {
+		return g.files[0].pathString() + "/"
+	}
+	return g.files[0].pathString()
+}
+
+func (f *file) pathString() string {
+	return strings.Join(f.fullPath(), "/")
+}
+
+// refreshGroups rebuilds the group list and the open group from the
+// duplicated hashes and app.byFolder after the duplicate index changed.
+// The list is left alone while the group screens are closed, as
+// openGroups rebuilds it.
+func (app *app) refreshGroups() {
+	app.panel.stale = true
+	if app.screen != screenGroups && app.screen != screenGroup {
+		return
+	}
+
+	list := &app.groupList
+	var selectedKey string
+	if list.selectedIdx < len(list.groups) {
+		selectedKey = list.groups[list.selectedIdx].key
+	}
+
+	list.groups = list.groups[:0]
+	for hash := range app.duplicated {
+		list.groups = append(list.groups, newGroup(hash, app.byHash[hash]))
+	}
+	for signature, members := range app.byFolder {
+		list.groups = append(list.groups, newGroup(signature, members))
+	}
+	list.sort()
+	for idx, group := range list.groups {
+		if group.key == selectedKey {
+			list.selectedIdx = idx
+		}
+	}
+
+	if app.group != nil {
+		if members := app.groupMembers(app.group.key); len(members) > 1 {
+			app.group = newGroup(app.group.key, members)
+		} else {
+			app.group = nil
+			if app.screen == screenGroup {
+				app.screen = screenGroups
+			}
+		}
+	}
+}
+
+func (list *groupList) sort() {
+	slices.SortFunc(list.groups, func(a, b *group) int {
+		var result int
+		switch list.sortColumn {
+		case groupByCopies:
+			result = cmp.Compare(len(a.files), len(b.files))
+		case groupBySize:
+			result = cmp.Compare(a.size(), b.size())
+		case groupByWasted:
+			result = cmp.Compare(a.wasted(), b.wasted())
+		}
+		if result == 0 {
+			result = cmp.Compare(strings.ToLower(a.name()), strings.ToLower(b.name()))
+		}
+		if !list.sortAscending[list.sortColumn] {
+			result = -result
+		}
+		return result
+	})
+}
+
+func (list *groupList) sortIndicator(column groupColumn) string {
+	if column == list.sortColumn {
+		if list.sortAscending[column] {
+			return " ▲"
+		}
+		return " ▼"
+	}
+	return ""
+}
+
+func (list *groupList) sortBy(column groupColumn) {
+	if column == list.sortColumn {
+		list.sortAscending[column] = !list.sortAscending[column]
+	} else {
+		list.sortColumn = column
+	}
+	list.sort()
+}
+
+func (app *app) openGroups() {
+	app.screen = screenGroups
+	app.refreshGroups()
+}
+
+func (app *app) openGroup(idx int) {
+	if idx >= len(app.groupList.groups) {
+		return
+	}
+	app.groupList.selectedIdx = idx
+	app.group = app.groupList.groups[idx]
+	app.members = cursor{}
+	app.screen = screenGroup
+}
+
+// revealFile shows file in the folder tree.
+func (app *app) revealFile(file *file) {
+	if app.search != "" {
+		app.setSearch("")
+	}
+	app.screen = screenFolder
+	app.curFolder = file.parent
+	app.selectFile(file)
+	app.curFolder.offsetIdx = app.curFolder.selectedIdx - app.folderHeight()/2
+}
+
+func (app *app) handleGroupsAction(a action) {
+	list := &app.groupList
+	if list.handleAction(a, len(list.groups), app.screenHeight-4) {
+		return
+	}
+	switch a {
+	case actionCancel, actionBack, actionGroups:
+		app.screen = screenFolder
+	case actionOpen, actionKeep:
+		app.openGroup(list.selectedIdx)
+	}
+}
+
+func (app *app) handleGroupAction(a action) {
+	if app.members.handleAction(a, len(app.group.files), app.screenHeight-4) {
+		return
+	}
+	switch a {
+	case actionCancel, actionBack:
+		app.screen = screenGroups
+	case actionGroups:
+		app.openGroups()
+	case actionOpen:
+		app.revealFile(app.group.files[app.members.selectedIdx])
+	case actionKeep:
+		app.confirmKeep(app.group.files[app.members.selectedIdx])
+	}
+}
+
+func (b *builder) renderGroups() {
+	list := &b.app.groupList
+	list.clamp(len(list.groups), b.app.screenHeight-4)
+
+	b.setStyle(b.theme.breadcrumbs)
+	b.text(fmt.Sprintf(" Duplicate Groups: %d", len(list.groups)))
+	b.newLine()
+
+	nameWidth := b.app.screenWidth - 49
+	b.setStyle(b.theme.folderHeader)
+	b.markPosition()
+	b.text(padRight("  Path"+list.sortIndicator(groupByName), nameWidth+2))
+	b.setTarget(groupSortCmd{groupByName})
+	b.markPosition()
+	b.text(padLeft("Copies"+list.sortIndicator(groupByCopies), 8))
+	b.setTarget(groupSortCmd{groupByCopies})
+	b.markPosition()
+	b.text(padLeft("Size"+list.sortIndicator(groupBySize), 19))
+	b.setTarget(groupSortCmd{groupBySize})
+	b.markPosition()
+	b.text(padLeft("Wasted"+list.sortIndicator(groupByWasted), 19))
+	b.setTarget(groupSortCmd{groupByWasted})
+	b.newLine()
+
+	for i := range b.app.screenHeight - 4 {
+		idx := i + list.offsetIdx
+		if idx >= len(list.groups) {
+			b.setStyle(b.theme.file)
+			b.newLine()
+			continue
+		}
+		group := list.groups[idx]
+		b.markPosition()
+		b.setRowStyle(idx == list.selectedIdx, true, false)
+		b.text("  ")
+		b.text(padRight(group.name(), nameWidth))
+		b.text(padLeft(fmt.Sprint(len(group.files)), 8))
+		b.text(formatSize(group.size()))
+		b.text(formatSize(group.wasted()))
+		b.text(" ")
+		b.setTarget(selectGroup{idx: idx})
+		b.newLine()
+	}
+}
+
+func (b *builder) renderGroup() {
+	group := b.app.group
+	b.app.members.clamp(len(group.files), b.app.screenHeight-4)
+
+	b.setStyle(b.theme.breadcrumbs)
+	b.markPosition()
+	b.text(" Duplicate Groups")
+	b.setTarget(actionGroups)
+	b.text(" / ")
+	b.text(group.name())
+	b.newLine()
+
+	b.setStyle(b.theme.folderHeader)
+	b.text(padRight("  Path", b.app.screenWidth-39))
+	b.text(padRight("Date Modified", 20))
+	b.text(padLeft("Size", 18))
+	b.newLine()
+
+	b.renderFileRows(group.files, &b.app.members, b.app.screenHeight-4, true, nil,
+		func(idx int) any { return selectMember{idx: idx} })
+}
+
+// renderFileRows lists files by path, highlighting the cursor row when focused
+// and marking current with a bullet; target builds the click command of a row.
+func (b *builder) renderFileRows(files files, cursor *cursor, height int, focused bool, current *file, target func(idx int) any) {
+	for i := range height {
+		idx := i + cursor.offsetIdx
+		if idx >= len(files) {
+			b.setStyle(b.theme.file)
+			b.newLine()
+			continue
+		}
+		file := files[idx]
+		b.markPosition()
+		protected := b.app.protected.Contains(file.pathString())
+		b.setRowStyle(focused && idx == cursor.selectedIdx, false, protected)
+		if file == current {
+			b.text("● ")
+		} else if protected && b.theme.markers {
+			b.text("◆ ")
+		} else {
+			b.text("  ")
+		}
+		b.text(padRight(file.pathString(), b.app.screenWidth-42))
+		b.text(file.modTime.Format(" 2006-01-02 15:04:05"))
+		b.text(formatSize(file.size))
+		b.text(" ")
+		b.setTarget(target(idx))
+		b.newLine()
+	}
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"slices"
+	"testing"
+
+	"dedup/fs"
+)
+
+// groupNames returns the names of the groups in list order.
+func (app *app) groupNames() (result []string) {
+	for _, group := range app.groupList.groups {
+		result = append(result, group.name())
+	}
+	return result
+}
+
+func TestGroupSorting(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "a/big", Size: 50, Hash: "h2"},
+		fs.FileMeta{Path: "b/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "c/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "d/big", Size: 50, Hash: "h2"},
+	)
+	app.openGroups()
+
+	for _, test := range []struct {
+		column groupColumn
+		want   []string
+	}{
+		{groupByCopies, []string{"a/x", "b/", "a/big"}},
+		{groupByWasted, []string{"a/big", "a/x", "b/"}},
+		{groupByName, []string{"a/big", "a/x", "b/"}},
+		{groupByName, []string{"b/", "a/x", "a/big"}},
+	} {
+		app.groupList.sortBy(test.column)
+		if names := app.groupNames(); !slices.Equal(names, test.want) {
+			t.Errorf("sorted by %d: %v, want %v", test.column, names, test.want)
+		}
+	}
+}
+
+func TestRefreshGroupsKeepsSelection(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "a/y", Size: 20, Hash: "h2"},
+		fs.FileMeta{Path: "c/y", Size: 20, Hash: "h2"},
+	)
+	app.openGroups()
+	if names := app.groupNames(); !slices.Equal(names, []string{"a/y", "a/x"}) {
+		t.Fatalf("groups %v", names)
+	}
+	app.groupList.selectedIdx = 1
+
+	app.addMeta(fs.FileMeta{Path: "a/z", Size: 30, ModTime: testTime, Hash: "h3"})
+	app.addMeta(fs.FileMeta{Path: "d/z", Size: 30, ModTime: testTime, Hash: "h3"})
+	app.analyze()
+	if names := app.groupNames(); !slices.Equal(names, []string{"a/z", "a/y", "a/x"}) {
+		t.Fatalf("groups after adding a/z and d/z: %v", names)
+	}
+	if selected := app.groupList.groups[app.groupList.selectedIdx].name(); selected != "a/x" {
+		t.Errorf("selection moved to %v", selected)
+	}
+}
+
+func TestKeepFromGroup(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "a/y", Size: 20, Hash: "h2"},
+		fs.FileMeta{Path: "b/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "c/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "c/y", Size: 20, Hash: "h2"},
+	)
+	app.openGroups()
+	app.groupList.sortBy(groupByName)
+	app.handleGroupsAction(actionOpen)
+	if app.screen != screenGroup || app.group.name() != "a/" {
+		t.Fatalf("opened %v on screen %v", app.group, app.screen)
+	}
+	app.handleGroupsAction(actionBack)
+	app.groupList.selectedIdx = 1
+	app.handleGroupsAction(actionOpen)
+	if app.group.name() != "a/x" || len(app.group.files) != 3 {
+		t.Fatalf("opened %v", app.group)
+	}
+
+	app.members.selectedIdx = 1
+	app.handleGroupAction(actionKeep)
+	if app.confirmation == nil {
+		t.Fatal("no confirmation")
+	}
+	app.handleConfirmKey("y")
+	if app.findFile([]string{"b", "x"}) == nil || app.findFile([]string{"a", "x"}) != nil || app.findFile([]string{"c", "x"}) != nil {
+		t.Error("kept the wrong copy")
+	}
+	if app.screen != screenGroups || app.group != nil {
+		t.Errorf("resolved group still open on screen %v", app.screen)
+	}
+	if names := app.groupNames(); !slices.Equal(names, []string{"a/", "a/y"}) {
+		t.Errorf("groups after keeping b/x: %v", names)
+	}
+}
+
+func TestGroupsRefreshOnlyWhileOpen(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 10, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 10, Hash: "h1"},
+	)
+	if len(app.groupList.groups) != 0 {
+		t.Fatalf("groups built on the folder screen: %v", app.groupNames())
+	}
+	app.openGroups()
+	if names := app.groupNames(); !slices.Equal(names, []string{"a/x", "a/"}) {
+		t.Fatalf("groups %v", names)
+	}
+
+	app.screen = screenFolder
+	app.addMeta(fs.FileMeta{Path: "c/y", Size: 20, ModTime: testTime, Hash: "h2"})
+	app.addMeta(fs.FileMeta{Path: "d/y", Size: 20, ModTime: testTime, Hash: "h2"})
+	app.analyze()
+	if names := app.groupNames(); !slices.Equal(names, []string{"a/x", "a/"}) {
+		t.Errorf("groups rebuilt on the folder screen: %v", names)
+	}
+	app.openGroups()
+	if names := app.groupNames(); !slices.Equal(names, []string{"c/y", "c/", "a/x", "a/"}) {
+		t.Errorf("groups after reopening: %v", names)
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// command is a line read from stdin in headless mode.
+type command struct {
+	name string
+}
+
+func readCommands(events events, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			events.Send(command{name: name})
+		}
+	}
+	events.Send(command{name: "quit"})
+}
+
+// watchSignals lets headless runs pause hashing with SIGUSR1
+// and resume it with SIGUSR2.
+func watchSignals(events events) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	for sig := range signals {
+		if sig == syscall.SIGUSR1 {
+			events.Send(command{name: "pause"})
+		} else {
+			events.Send(command{name: "resume"})
+		}
+	}
+}
+
+func (app *app) runCommand(cmd command) tea.Cmd {
+	switch cmd.name {
+	case "rescan":
+		app.rescan()
+	case "pause":
+		app.setPaused(true)
+	case "resume":
+		app.setPaused(false)
+	case "quit":
+		app.quitting = true
+	default:
+		app.report("unknown command %q", cmd.name)
+	}
+	if app.quitting && app.state == archiveReady {
+		return tea.Quit
+	}
+	return nil
+}
+
+func (app *app) report(format string, args ...any) {
+	if app.headless {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
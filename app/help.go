@@ -0,0 +1,93 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+type helpRow struct {
+	keys    string
+	text    string
+	heading bool
+}
+
+// helpRows lists the key bindings from the action registry and the mouse interactions.
+func (app *app) helpRows() []helpRow {
+	rows := []helpRow{{text: "Keys", heading: true}}
+	for _, def := range actions {
+		if def.dialog {
+			continue
+		}
+		rows = append(rows, helpRow{keys: app.keyHint(def.action), text: def.help})
+	}
+	rows = append(rows, helpRow{text: "Confirmation Dialog", heading: true})
+	for _, def := range actions {
+		if def.dialog {
+			rows = append(rows, helpRow{keys: strings.Join(app.dialogKeys.keysOf(def.action), "/"), text: def.help})
+		}
+	}
+	rows = append(rows, helpRow{text: "Mouse", heading: true})
+	for _, mouse := range mouseHelp {
+		rows = append(rows, helpRow{keys: mouse[0], text: mouse[1]})
+	}
+	return rows
+}
+
+func (app *app) helpHeight() int {
+	return app.screenHeight - 3
+}
+
+func (app *app) toggleHelp() {
+	app.help = !app.help
+	app.helpCursor = cursor{}
+}
+
+func (app *app) handleHelpAction(a action) {
+	c := &app.helpCursor
+	switch a {
+	case actionUp:
+		c.offsetIdx--
+	case actionDown:
+		c.offsetIdx++
+	case actionPageUp:
+		c.offsetIdx -= app.helpHeight()
+	case actionPageDown:
+		c.offsetIdx += app.helpHeight()
+	case actionHome:
+		c.offsetIdx = 0
+	case actionEnd:
+		c.offsetIdx = len(app.helpRows()) - app.helpHeight()
+	case actionHelp, actionCancel, actionBack:
+		app.help = false
+	}
+}
+
+func (b *builder) renderHelp() {
+	rows := b.app.helpRows()
+	height := b.app.helpHeight()
+	c := &b.app.helpCursor
+	c.selectedIdx = c.offsetIdx
+	c.clamp(len(rows), height)
+
+	b.setStyle(b.theme.breadcrumbs)
+	b.text(fmt.Sprintf(" Help  (%s: close)", b.app.keyHint(actionHelp)))
+	b.newLine()
+
+	for i := range height {
+		idx := i + c.offsetIdx
+		if idx >= len(rows) {
+			b.setStyle(b.theme.file)
+			b.newLine()
+			continue
+		}
+		row := rows[idx]
+		if row.heading {
+			b.setStyle(b.theme.folderHeader)
+			b.text(" " + row.text)
+		} else {
+			b.setStyle(b.theme.file)
+			b.text("   " + padRight(row.keys, 18) + " " + row.text)
+		}
+		b.newLine()
+	}
+}
@@ -0,0 +1,16 @@
+package app
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHelpListsEveryAction(t *testing.T) {
+	app := newTestApp()
+	rows := app.helpRows()
+	for _, def := range actions {
+		if !slices.ContainsFunc(rows, func(row helpRow) bool { return row.text == def.help && row.keys != "" }) {
+			t.Errorf("help misses %q", def.name)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// indexFile adds a hashed file to app.byHash and updates the dup counts of its group.
+func (app *app) indexFile(file *file) {
+	if file.hash == "" {
+		return
+	}
+	group := append(app.byHash[file.hash], file)
+	app.byHash[file.hash] = group
+	if len(group) == 2 {
+		app.nDuplicates++
+		app.duplicated[file.hash] = struct{}{}
+	}
+	app.unsigned[file.parent] = struct{}{}
+	app.setDups(group)
+}
+
+// unindexFile removes file from app.byHash, dropping groups left empty.
+func (app *app) unindexFile(file *file) {
+	group := app.byHash[file.hash]
+	idx := slices.Index(group, file)
+	if idx < 0 {
+		return
+	}
+	group = slices.Delete(slices.Clone(group), idx, idx+1)
+	if len(group) == 0 {
+		delete(app.byHash, file.hash)
+	} else {
+		app.byHash[file.hash] = group
+	}
+	if len(group) == 1 {
+		app.nDuplicates--
+		delete(app.duplicated, file.hash)
+	}
+	app.unsigned[file.parent] = struct{}{}
+	app.setDups(files{file})
+	app.setDups(group)
+}
+
+func (app *app) setDups(group files) {
+	dups := len(group)
+	if dups < 2 {
+		dups = 0
+	}
+	for _, file := range group {
+		if file.dups != dups {
+			file.dups = dups
+			app.markDirty(file, false)
+		}
+	}
+}
+
+// markDirty schedules the folders above file for flushDirty. Folders whose
+// contents changed, not just dup counts, are also re-sorted and re-signed.
+func (app *app) markDirty(file *file, resort bool) {
+	if resort {
+		app.unsigned[file.parent] = struct{}{}
+	}
+	for folder := file.parent; folder != nil; folder = folder.parent {
+		if sorted, ok := app.dirty[folder]; ok && (sorted || !resort) {
+			break
+		}
+		app.dirty[folder] = app.dirty[folder] || resort
+	}
+}
+
+// flushDirty recomputes size, dups and modTime of the folders changed since
+// the last flush, deepest first, so that only the changed parent chains are visited.
+func (app *app) flushDirty() {
+	if len(app.dirty) == 0 {
+		return
+	}
+	selected := app.selectedFile()
+
+	folders := make([]*file, 0, len(app.dirty))
+	for folder := range app.dirty {
+		folders = append(folders, folder)
+	}
+	slices.SortFunc(folders, func(a, b *file) int {
+		return cmp.Compare(b.depth(), a.depth())
+	})
+	for _, folder := range folders {
+		folder.sumChildren()
+		if app.dirty[folder] || columns[folder.sortColumn].volatile {
+			folder.sort()
+		}
+	}
+	clear(app.dirty)
+	app.invalidateView()
+
+	app.selectFile(selected)
+}
+
+func (folder *file) sumChildren() {
+	folder.size = 0
+	folder.modTime = time.Time{}
+	folder.dups = 0
+	folder.wasted = 0
+	for _, child := range folder.children {
+		folder.updateMeta(child)
+	}
+}
+
+func (f *file) depth() int {
+	depth := 0
+	for ; f.parent != nil; f = f.parent {
+		depth++
+	}
+	return depth
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"dedup/fs"
+)
+
+func TestIncrementalIndex(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 1},
+		fs.FileMeta{Path: "b/y", Size: 2, ModTime: testTime.Add(time.Hour), Hash: "h2"},
+	)
+
+	b := app.findFile([]string{"b"})
+	if app.nDuplicates != 0 || b.dups != 0 {
+		t.Fatalf("unhashed file counted as duplicate: %d groups, folder dups %d", app.nDuplicates, b.dups)
+	}
+
+	bx := app.findFile([]string{"b", "x"})
+	app.unindexFile(bx)
+	bx.hash = "h1"
+	app.indexFile(bx)
+	app.flushDirty()
+	if app.nDuplicates != 1 || bx.dups != 2 || b.dups != 2 || app.rootFolder.dups != 4 {
+		t.Fatalf("hashed duplicate not indexed: %d groups, file dups %d, folder dups %d, root dups %d",
+			app.nDuplicates, bx.dups, b.dups, app.rootFolder.dups)
+	}
+
+	app.removeFile(app.findFile([]string{"a", "x"}))
+	app.flushDirty()
+	if app.nDuplicates != 0 || bx.dups != 0 || app.rootFolder.dups != 0 || len(app.byHash["h1"]) != 1 {
+		t.Errorf("removed duplicate left stale index: %d groups, %v", app.nDuplicates, app.byHash["h1"])
+	}
+
+	app.removeFile(app.findFile([]string{"b", "y"}))
+	app.flushDirty()
+	if b.size != 1 || !b.modTime.Equal(testTime) || app.rootFolder.size != 1 {
+		t.Errorf("folder aggregates not updated: %v, root size %d", b, app.rootFolder.size)
+	}
+	if _, ok := app.byHash["h2"]; ok {
+		t.Error("empty group left in the index")
+	}
+}
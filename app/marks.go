@@ -0,0 +1,210 @@
+package app
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"dedup/fs"
+)
+
+type batchOp int
+
+const (
+	batchDelete batchOp = iota
+	batchTrash
+	batchLink
+	batchExport
+)
+
+func (app *app) isMarked(file *file) bool {
+	_, ok := app.marked[file]
+	return ok
+}
+
+// toggleMark marks or unmarks file; for a folder it marks every file
+// beneath it unless they are all marked already.
+func (app *app) toggleMark(target *file) {
+	var all files
+	target.collectTree(&all)
+	mark := slices.ContainsFunc(all, func(f *file) bool { return !app.isMarked(f) })
+	for _, f := range all {
+		app.setMark(f, mark)
+	}
+}
+
+func (app *app) setMark(file *file, mark bool) {
+	if mark {
+		app.marked[file] = struct{}{}
+	} else {
+		delete(app.marked, file)
+	}
+}
+
+// markDuplicates marks the duplicated files of the current folder that are not protected.
+func (app *app) markDuplicates() {
+	for _, child := range app.children(app.curFolder) {
+		if child.folder == nil && child.dups > 0 && !app.isProtected(child) {
+			app.setMark(child, true)
+		}
+	}
+}
+
+// invertMarks inverts the marks of the files in the current folder.
+func (app *app) invertMarks() {
+	for _, child := range app.children(app.curFolder) {
+		if child.folder == nil {
+			app.setMark(child, !app.isMarked(child))
+		}
+	}
+}
+
+func (app *app) clearMarks() {
+	clear(app.marked)
+}
+
+func (app *app) markedFiles() files {
+	result := make(files, 0, len(app.marked))
+	for file := range app.marked {
+		result = append(result, file)
+	}
+	slices.SortFunc(result, func(a, b *file) int {
+		return cmp.Compare(a.pathString(), b.pathString())
+	})
+	return result
+}
+
+func (app *app) markedSize() int {
+	size := 0
+	for file := range app.marked {
+		size += file.size
+	}
+	return size
+}
+
+// unmarkedCopy returns a copy of file that is not marked, so that batch
+// operations never remove the last copy of a file.
+func (app *app) unmarkedCopy(file *file) *file {
+	for _, dup := range app.byHash[file.hash] {
+		if dup != file && !app.isMarked(dup) {
+			return dup
+		}
+	}
+	return nil
+}
+
+func (app *app) runBatch(op batchOp) {
+	if len(app.marked) == 0 {
+		app.message = "No files marked"
+		return
+	}
+	if op == batchExport {
+		app.exportMarks()
+		return
+	}
+
+	var batch files
+	skipped := 0
+	for _, file := range app.markedFiles() {
+		if app.isProtected(file) || app.unmarkedCopy(file) == nil {
+			skipped++
+			continue
+		}
+		batch = append(batch, file)
+	}
+	if len(batch) == 0 {
+		app.message = fmt.Sprintf("Kept %d protected files or files with no unmarked copy", skipped)
+		return
+	}
+	title := [...]string{"Delete %d marked files?", "Move %d marked files to trash?", "Replace %d marked files with hard links?"}[op]
+	app.confirm(fmt.Sprintf(title, len(batch)), batch, func() {
+		app.applyBatch(op, batch, skipped)
+	})
+}
+
+func (app *app) applyBatch(op batchOp, batch files, skipped int) {
+	done, failed, freed := 0, 0, 0
+	var lastErr, auditErr error
+	for _, file := range batch {
+		keep := app.unmarkedCopy(file)
+		if keep == nil {
+			skipped++
+			continue
+		}
+		var err error
+		switch op {
+		case batchDelete:
+			err = app.fs.Remove(file.meta(), keep.meta())
+		case batchTrash:
+			err = app.fs.Trash(file.meta(), keep.meta())
+		case batchLink:
+			err = app.fs.Link(file.meta(), keep.meta())
+		}
+		if errors.Is(err, fs.ErrNotAudited) {
+			auditErr = err
+		} else if err != nil {
+			app.applyChange(err)
+			failed++
+			lastErr = err
+			continue
+		}
+		if op != batchLink {
+			app.removeFile(file)
+		}
+		delete(app.marked, file)
+		done++
+		freed += file.size
+	}
+	app.analyze()
+
+	verb := [...]string{"Deleted", "Trashed", "Linked"}[op]
+	app.message = fmt.Sprintf("%s %d files, freed %s", verb, done, formatBytes(freed))
+	if skipped > 0 {
+		app.message += fmt.Sprintf("; kept %d protected files or files with no unmarked copy", skipped)
+	}
+	if failed > 0 {
+		app.message += fmt.Sprintf("; left %d files in place: %v", failed, lastErr)
+	}
+	if auditErr != nil {
+		app.message += fmt.Sprintf("; %v", auditErr)
+	}
+}
+
+func (app *app) exportMarks() {
+	name := "dedup-marked-" + time.Now().Format("20060102-150405") + ".csv"
+	records := [][]string{{"Path", "Size", "ModTime", "Hash"}}
+	for _, file := range app.markedFiles() {
+		records = append(records, []string{
+			filepath.Join(app.fs.Root(), file.pathString()),
+			fmt.Sprint(file.size),
+			file.modTime.UTC().Format(time.RFC3339),
+			file.hash,
+		})
+	}
+
+	exportFile, err := os.Create(name)
+	if err == nil {
+		err = csv.NewWriter(exportFile).WriteAll(records)
+		_ = exportFile.Close()
+	}
+	if err != nil {
+		app.message = fmt.Sprintf("Export failed: %v", err)
+		return
+	}
+	app.message = fmt.Sprintf("Exported %d files to %s", len(app.marked), name)
+}
+
+func (f *file) collectTree(result *files) {
+	if f.folder == nil {
+		*result = append(*result, f)
+		return
+	}
+	for _, child := range f.children {
+		child.collectTree(result)
+	}
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"testing"
+
+	"dedup/fs"
+)
+
+func TestBatchDeleteKeepsLastCopy(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "a/y", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "b/y", Size: 2, Hash: "h2"},
+	)
+
+	app.toggleMark(app.findFile([]string{"a"}))
+	app.toggleMark(app.findFile([]string{"b", "y"}))
+	app.runBatch(batchDelete)
+	if app.confirmation == nil || len(app.confirmation.paths) != 1 {
+		t.Fatalf("expected confirmation of one file, got %v", app.confirmation)
+	}
+	app.handleConfirmKey("y")
+
+	if app.findFile([]string{"a", "x"}) != nil {
+		t.Error("marked duplicate was not deleted")
+	}
+	if app.findFile([]string{"b", "x"}) == nil {
+		t.Error("unmarked copy was deleted")
+	}
+	if app.findFile([]string{"a", "y"}) == nil || app.findFile([]string{"b", "y"}) == nil {
+		t.Error("a copy of a fully marked group was deleted")
+	}
+	if len(app.marked) != 2 {
+		t.Errorf("expected the skipped files to stay marked, got %d marks", len(app.marked))
+	}
+}
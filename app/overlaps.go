@@ -0,0 +1,335 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type (
+	// overlap compares the files beneath two folders by content.
+	overlap struct {
+		a, b        *file
+		shared      int
+		sharedBytes int
+		uniqueA     int
+		uniqueB     int
+		uniqueBytes int
+	}
+
+	overlapList struct {
+		pairs []*overlap
+		cursor
+	}
+
+	diffRow struct {
+		a, b *file
+	}
+
+	selectOverlap struct {
+		idx int
+	}
+)
+
+func (o *overlap) similarity() float64 {
+	return float64(o.shared) / float64(o.shared+o.uniqueA+o.uniqueB)
+}
+
+// folderHashes returns the distinct hashes of the files beneath folder.
+func folderHashes(folder *file) map[string]*file {
+	result := map[string]*file{}
+	var walk func(folder *file)
+	walk = func(folder *file) {
+		for _, child := range folder.children {
+			if child.folder != nil {
+				walk(child)
+			} else if _, ok := result[child.hash]; !ok && child.hash != "" {
+				result[child.hash] = child
+			}
+		}
+	}
+	walk(folder)
+	return result
+}
+
+// maxOverlapFolders caps the folders a duplicated hash may be spread over
+// to pair them up; files copied into that many folders, such as licenses,
+// say little about which folders overlap.
+const maxOverlapFolders = 100
+
+// analyzeOverlaps ranks pairs of folders sharing file content, using
+// the duplicated hashes to find the folders that have anything in common. Each pair of
+// folders holding copies is paired up, then the folders above those level
+// by level, so that mirrored trees such as Photos and Backup/Photos are
+// compared as a whole; folders are never paired with their own ancestors.
+// Climbing stops at a pair found before, as the pairs above it are known.
+func (app *app) analyzeOverlaps() []*overlap {
+	type pairKey struct{ a, b *file }
+	pairs := map[pairKey]struct{}{}
+	for hash := range app.duplicated {
+		var folders files
+		seen := map[*file]bool{}
+		for _, file := range app.byHash[hash] {
+			if !seen[file.parent] && len(folders) <= maxOverlapFolders {
+				seen[file.parent] = true
+				folders = append(folders, file.parent)
+			}
+		}
+		if len(folders) > maxOverlapFolders {
+			continue
+		}
+		for i, x := range folders {
+			for _, y := range folders[i+1:] {
+				a, b := x, y
+				for a != nil && b != nil && !a.contains(b) && !b.contains(a) {
+					if _, ok := pairs[pairKey{b, a}]; ok {
+						break
+					}
+					if _, ok := pairs[pairKey{a, b}]; ok {
+						break
+					}
+					pairs[pairKey{a, b}] = struct{}{}
+					a, b = a.parent, b.parent
+				}
+			}
+		}
+	}
+
+	hashes := map[*file]map[string]*file{}
+	folderHashesOf := func(folder *file) map[string]*file {
+		if result, ok := hashes[folder]; ok {
+			return result
+		}
+		hashes[folder] = folderHashes(folder)
+		return hashes[folder]
+	}
+
+	result := make([]*overlap, 0, len(pairs))
+	for pair := range pairs {
+		if pair.a.pathString() > pair.b.pathString() {
+			pair.a, pair.b = pair.b, pair.a
+		}
+		hashesA, hashesB := folderHashesOf(pair.a), folderHashesOf(pair.b)
+		overlap := &overlap{a: pair.a, b: pair.b}
+		for hash, file := range hashesA {
+			if _, ok := hashesB[hash]; ok {
+				overlap.shared++
+				overlap.sharedBytes += file.size
+			} else {
+				overlap.uniqueA++
+				overlap.uniqueBytes += file.size
+			}
+		}
+		for hash, file := range hashesB {
+			if _, ok := hashesA[hash]; !ok {
+				overlap.uniqueB++
+				overlap.uniqueBytes += file.size
+			}
+		}
+		result = append(result, overlap)
+	}
+
+	slices.SortFunc(result, func(x, y *overlap) int {
+		if bySimilarity := cmp.Compare(y.similarity(), x.similarity()); bySimilarity != 0 {
+			return bySimilarity
+		}
+		if byBytes := cmp.Compare(y.sharedBytes, x.sharedBytes); byBytes != 0 {
+			return byBytes
+		}
+		return cmp.Compare(x.a.pathString()+"\x00"+x.b.pathString(), y.a.pathString()+"\x00"+y.b.pathString())
+	})
+	return result
+}
+
+// contains reports whether f is folder or lies beneath it.
+func (folder *file) contains(f *file) bool {
+	for ; f != nil; f = f.parent {
+		if f == folder {
+			return true
+		}
+	}
+	return false
+}
+
+// diffRows lines up the files beneath both folders of o: shared content side by side,
+// then the files found in only one of them, ordered by their path in the folder.
+func (o *overlap) diffRows() []diffRow {
+	hashesA, hashesB := folderHashes(o.a), folderHashes(o.b)
+	var rows []diffRow
+	for hash, file := range hashesA {
+		rows = append(rows, diffRow{a: file, b: hashesB[hash]})
+	}
+	for hash, file := range hashesB {
+		if _, ok := hashesA[hash]; !ok {
+			rows = append(rows, diffRow{b: file})
+		}
+	}
+	slices.SortFunc(rows, func(x, y diffRow) int {
+		return cmp.Compare(strings.ToLower(o.rowPath(x)), strings.ToLower(o.rowPath(y)))
+	})
+	return rows
+}
+
+func (o *overlap) rowPath(row diffRow) string {
+	if row.a != nil {
+		return relativePath(row.a, o.a)
+	}
+	return relativePath(row.b, o.b)
+}
+
+// relativePath returns the path of f beneath folder.
+func relativePath(f, folder *file) string {
+	return strings.TrimPrefix(f.pathString(), folder.pathString()+"/")
+}
+
+func (app *app) openOverlaps() {
+	app.screen = screenOverlaps
+	app.overlaps.pairs = app.analyzeOverlaps()
+}
+
+func (app *app) openOverlap(idx int) {
+	if idx >= len(app.overlaps.pairs) {
+		return
+	}
+	app.overlaps.selectedIdx = idx
+	app.overlap = app.overlaps.pairs[idx]
+	app.diffRows = app.overlap.diffRows()
+	app.diff = cursor{}
+	app.screen = screenOverlap
+}
+
+// refreshOverlaps recomputes the open overlap screens after the duplicate index changed.
+func (app *app) refreshOverlaps() {
+	switch app.screen {
+	case screenOverlaps:
+		app.overlaps.pairs = app.analyzeOverlaps()
+	case screenOverlap:
+		diff := app.diff
+		app.overlaps.pairs = app.analyzeOverlaps()
+		app.screen = screenOverlaps
+		for idx, pair := range app.overlaps.pairs {
+			if pair.a == app.overlap.a && pair.b == app.overlap.b {
+				app.openOverlap(idx)
+				app.diff = diff
+			}
+		}
+	}
+}
+
+func (app *app) handleOverlapsAction(a action) {
+	list := &app.overlaps
+	if list.handleAction(a, len(list.pairs), app.screenHeight-4) {
+		return
+	}
+	switch a {
+	case actionCancel, actionBack, actionOverlaps:
+		app.screen = screenFolder
+	case actionOpen, actionKeep:
+		app.openOverlap(list.selectedIdx)
+	}
+}
+
+func (app *app) handleOverlapAction(a action) {
+	if app.diff.handleAction(a, len(app.diffRows), app.screenHeight-4) {
+		return
+	}
+	switch a {
+	case actionCancel, actionBack:
+		app.screen = screenOverlaps
+	case actionOverlaps:
+		app.screen = screenFolder
+	}
+}
+
+func (b *builder) renderOverlaps() {
+	list := &b.app.overlaps
+	list.clamp(len(list.pairs), b.app.screenHeight-4)
+
+	b.setStyle(b.theme.breadcrumbs)
+	b.text(fmt.Sprintf(" Overlapping Folders: %d", len(list.pairs)))
+	b.newLine()
+
+	pathWidth := (b.app.screenWidth - 43) / 2
+	b.setStyle(b.theme.folderHeader)
+	b.text(padRight("  Folder A", pathWidth+2))
+	b.text(padRight("  Folder B", pathWidth+2))
+	b.text(padLeft("Match", 6))
+	b.text(padLeft("Shared", 8))
+	b.text(padLeft("Bytes", 10))
+	b.text(padLeft("Only A", 7))
+	b.text(padLeft("Only B", 7))
+	b.newLine()
+
+	for i := range b.app.screenHeight - 4 {
+		idx := i + list.offsetIdx
+		if idx >= len(list.pairs) {
+			b.setStyle(b.theme.file)
+			b.newLine()
+			continue
+		}
+		pair := list.pairs[idx]
+		b.markPosition()
+		b.setRowStyle(idx == list.selectedIdx, false, false)
+		b.text("  ")
+		b.text(padRight(pair.a.pathString(), pathWidth))
+		b.text("  ")
+		b.text(padRight(pair.b.pathString(), pathWidth))
+		b.text(padLeft(fmt.Sprintf("%.0f%%", pair.similarity()*100), 6))
+		b.text(padLeft(formatCount(pair.shared), 8))
+		b.text(padLeft(formatBytes(pair.sharedBytes), 10))
+		b.text(padLeft(formatCount(pair.uniqueA), 7))
+		b.text(padLeft(formatCount(pair.uniqueB), 7))
+		b.text(" ")
+		b.setTarget(selectOverlap{idx: idx})
+		b.newLine()
+	}
+}
+
+func (b *builder) renderOverlap() {
+	pair := b.app.overlap
+	b.app.diff.clamp(len(b.app.diffRows), b.app.screenHeight-4)
+
+	b.setStyle(b.theme.breadcrumbs)
+	b.text(" ")
+	b.text(fmt.Sprintf("Shared %d files %s, unique %d files %s",
+		pair.shared, formatBytes(pair.sharedBytes), pair.uniqueA+pair.uniqueB, formatBytes(pair.uniqueBytes)))
+	b.newLine()
+
+	sideWidth := (b.app.screenWidth - 3) / 2
+	b.setStyle(b.theme.folderHeader)
+	b.text(padRight(" "+pair.a.pathString(), sideWidth))
+	b.text("   ")
+	b.text(padRight(pair.b.pathString(), sideWidth))
+	b.newLine()
+
+	for i := range b.app.screenHeight - 4 {
+		idx := i + b.app.diff.offsetIdx
+		if idx >= len(b.app.diffRows) {
+			b.setStyle(b.theme.file)
+			b.newLine()
+			continue
+		}
+		row := b.app.diffRows[idx]
+		b.setRowStyle(idx == b.app.diff.selectedIdx, row.a != nil && row.b != nil, false)
+		b.text(" ")
+		b.text(diffCell(row.a, pair.a, sideWidth-1))
+		switch {
+		case row.a == nil:
+			b.text(" > ")
+		case row.b == nil:
+			b.text(" < ")
+		default:
+			b.text(" = ")
+		}
+		b.text(diffCell(row.b, pair.b, sideWidth))
+		b.newLine()
+	}
+}
+
+func diffCell(file, folder *file, width int) string {
+	if file == nil {
+		return padRight("", width)
+	}
+	return padRight(relativePath(file, folder), width-11) + padLeft(formatBytes(file.size), 11)
+}
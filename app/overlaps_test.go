@@ -0,0 +1,75 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"dedup/fs"
+)
+
+func TestOverlaps(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "Photos/2019/a.jpg", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "Photos/2019/b.jpg", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "Photos/2020/c.jpg", Size: 3, Hash: "h3"},
+		fs.FileMeta{Path: "Photos/d.jpg", Size: 4, Hash: "h4"},
+		fs.FileMeta{Path: "Backup/Photos/2019/a.jpg", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "Backup/Photos/2019/b.jpg", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "Backup/Photos/2020/c.jpg", Size: 3, Hash: "h3"},
+		fs.FileMeta{Path: "Backup/Photos/e.jpg", Size: 5, Hash: "h5"},
+	)
+
+	overlaps := app.analyzeOverlaps()
+	var pairs []string
+	for _, o := range overlaps {
+		pairs = append(pairs, o.a.pathString()+" ~ "+o.b.pathString())
+	}
+	want := []string{
+		"Backup/Photos/2019 ~ Photos/2019",
+		"Backup/Photos/2020 ~ Photos/2020",
+		"Backup/Photos ~ Photos",
+	}
+	if !slices.Equal(pairs, want) {
+		t.Fatalf("got overlaps %v, want %v", pairs, want)
+	}
+	photos := overlaps[2]
+	if photos.shared != 3 || photos.uniqueA != 1 || photos.uniqueB != 1 {
+		t.Errorf("Photos overlap: shared %d, unique %d and %d", photos.shared, photos.uniqueA, photos.uniqueB)
+	}
+
+	var rows []string
+	for _, row := range photos.diffRows() {
+		side := "="
+		if row.b == nil {
+			side = "<"
+		} else if row.a == nil {
+			side = ">"
+		}
+		rows = append(rows, side+" "+photos.rowPath(row))
+	}
+	want = []string{"= 2019/a.jpg", "= 2019/b.jpg", "= 2020/c.jpg", "> d.jpg", "< e.jpg"}
+	if !slices.Equal(rows, want) {
+		t.Errorf("got rows %v, want %v", rows, want)
+	}
+}
+
+func TestOverlapsSkipCommonFiles(t *testing.T) {
+	metas := []fs.FileMeta{
+		{Path: "a/x", Size: 1, Hash: "h1"},
+		{Path: "a/y", Size: 1, Hash: "h1"},
+		{Path: "b/x", Size: 1, Hash: "h1"},
+	}
+	for i := range maxOverlapFolders + 1 {
+		metas = append(metas, fs.FileMeta{Path: fmt.Sprintf("p%d/LICENSE", i), Size: 2, Hash: "h2"})
+	}
+	app := newTestArchive(metas...)
+
+	var pairs []string
+	for _, o := range app.analyzeOverlaps() {
+		pairs = append(pairs, o.a.pathString()+" ~ "+o.b.pathString())
+	}
+	if !slices.Equal(pairs, []string{"a ~ b"}) {
+		t.Errorf("got overlaps %v", pairs)
+	}
+}
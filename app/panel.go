@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+)
+
+const maxPanelRows = 6
+
+// copiesPanel lists every copy of the selected file below the folder view
+// and lets the user choose which copy to keep.
+type copiesPanel struct {
+	file    *file
+	group   *group
+	focused bool
+	stale   bool
+	cursor
+}
+
+func (app *app) syncPanel() {
+	panel := &app.panel
+	file := app.selectedFile()
+	if app.screen != screenFolder || file == nil || len(app.copiesOf(file)) < 2 {
+		*panel = copiesPanel{}
+		return
+	}
+	if panel.file == file && !panel.stale {
+		return
+	}
+
+	selected := file
+	if panel.file == file && panel.selectedIdx < len(panel.group.files) {
+		selected = panel.group.files[panel.selectedIdx]
+	} else {
+		panel.focused = false
+	}
+	panel.file = file
+	panel.group = newGroup(file.copiesKey(), app.copiesOf(file))
+	panel.stale = false
+	panel.selectedIdx = max(slices.Index(panel.group.files, selected), slices.Index(panel.group.files, file))
+}
+
+func (app *app) panelHeight() int {
+	app.syncPanel()
+	if app.panel.group == nil {
+		return 0
+	}
+	return min(len(app.panel.group.files), maxPanelRows) + 1
+}
+
+func (app *app) folderHeight() int {
+	return app.screenHeight - 4 - app.panelHeight()
+}
+
+func (app *app) focusPanel() {
+	app.syncPanel()
+	app.panel.focused = app.panel.group != nil
+}
+
+func (app *app) handlePanelAction(a action) {
+	panel := &app.panel
+	if panel.handleAction(a, len(panel.group.files), app.panelHeight()-1) {
+		return
+	}
+	switch a {
+	case actionCancel, actionBack, actionCopies:
+		panel.focused = false
+	case actionOpen:
+		app.revealFile(panel.group.files[panel.selectedIdx])
+	case actionKeep:
+		app.confirmKeep(panel.group.files[panel.selectedIdx])
+	}
+}
+
+func (b *builder) renderPanel() {
+	height := b.app.panelHeight() - 1
+	panel := &b.app.panel
+	if panel.group == nil {
+		return
+	}
+	panel.clamp(len(panel.group.files), height)
+
+	b.setStyle(b.theme.folderHeader)
+	b.text(fmt.Sprintf(" Copies of %s: %d", panel.file.name, len(panel.group.files)))
+	if panel.focused {
+		b.text(fmt.Sprintf("  (%s: keep selected copy, %s: back)", b.app.keyHint(actionKeep), b.app.keyHint(actionCancel)))
+	} else {
+		b.text(fmt.Sprintf("  (%s: choose copy to keep)", b.app.keyHint(actionCopies)))
+	}
+	b.newLine()
+
+	b.renderFileRows(panel.group.files, &panel.cursor, height, panel.focused, panel.file,
+		func(idx int) any { return selectMember{idx: idx, panel: true} })
+}
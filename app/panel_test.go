@@ -0,0 +1,47 @@
+package app
+
+import (
+	"testing"
+
+	"dedup/fs"
+)
+
+func TestKeepFromPanel(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "c/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "c/y", Size: 2, Hash: "h2"},
+	)
+	app.screenHeight = 20
+	app.curFolder = app.findFile([]string{"a"})
+	a, b := app.findFile([]string{"a", "x"}), app.findFile([]string{"b", "x"})
+	app.selectFile(a)
+
+	app.focusPanel()
+	panel := &app.panel
+	if !panel.focused || panel.file != a || len(panel.group.files) != 3 || panel.group.files[panel.selectedIdx] != a {
+		t.Fatalf("panel of %v focused %v on copy %d", panel.file, panel.focused, panel.selectedIdx)
+	}
+	app.handlePanelAction(actionDown)
+	if panel.group.files[panel.selectedIdx] != b {
+		t.Fatalf("panel cursor on %v", panel.group.files[panel.selectedIdx])
+	}
+	panel.stale = true
+	app.syncPanel()
+	if !panel.focused || panel.group.files[panel.selectedIdx] != b {
+		t.Fatalf("refreshing the panel moved its cursor to %v", panel.group.files[panel.selectedIdx])
+	}
+
+	app.handlePanelAction(actionKeep)
+	if app.confirmation == nil {
+		t.Fatal("no confirmation")
+	}
+	app.handleConfirmKey("y")
+	if app.findFile([]string{"b", "x"}) == nil || app.findFile([]string{"a", "x"}) != nil || app.findFile([]string{"c", "x"}) != nil {
+		t.Error("kept the wrong copy")
+	}
+	if app.findFile([]string{"c", "y"}) == nil {
+		t.Error("removed a file that is no copy")
+	}
+}
@@ -7,51 +7,72 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-var (
-	styleDefault         = lipgloss.NewStyle().Foreground(lipgloss.Color("17")).Background(lipgloss.Color("250"))
-	styleScreenTooSmall  = lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4")).Background(lipgloss.Color("9")).Bold(true)
-	styleArchive         = lipgloss.NewStyle().Foreground(lipgloss.Color("226")).Background(lipgloss.Color("0")).Bold(true).Italic(true)
-	styleBreadcrumbs     = lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("17")).Bold(true)
-	styleFile            = lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("17"))
-	styleFileDup         = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Background(lipgloss.Color("17"))
-	styleFileSelected    = lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("19"))
-	styleFileDupSelected = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Background(lipgloss.Color("19"))
-	styleFolderHeader    = lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Background(lipgloss.Color("243")).Bold(true)
-	styleProgressBar     = lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("33")).Bold(true)
-)
-
 type builder struct {
 	app          *app
 	builder      strings.Builder
 	x, y         int
+	theme        *theme
 	style        lipgloss.Style
+	selectMarker bool
 	markX, markY int
 }
 
 func (app *app) render() string {
 	app.targets = app.targets[:0]
-	b := builder{app: app, builder: strings.Builder{}}
+	b := builder{app: app, builder: strings.Builder{}, theme: app.theme}
 
 	if app.screenWidth < 80 || app.screenHeight < 24 {
 		return b.renderTooSmall()
 	}
 
 	b.renderTitle()
-	b.renderBreadcrumbs()
-	b.renderFolder()
+	if app.confirmation != nil {
+		b.renderConfirmation()
+		return b.builder.String()
+	}
+	if app.help {
+		b.renderHelp()
+		b.renderStatusLine()
+		return b.builder.String()
+	}
+	switch app.screen {
+	case screenGroups:
+		b.renderGroups()
+	case screenGroup:
+		b.renderGroup()
+	case screenOverlaps:
+		b.renderOverlaps()
+	case screenOverlap:
+		b.renderOverlap()
+	case screenResults:
+		b.renderResults()
+	default:
+		b.renderBreadcrumbs()
+		b.renderFolder()
+	}
 	b.renderStatusLine()
 	return b.builder.String()
 }
 
 func (b *builder) renderTitle() {
-	b.setStyle(styleArchive)
+	b.setStyle(b.theme.archive)
 	b.text(" ")
 	b.text(b.app.fs.Root())
+	if b.app.readOnly {
+		b.text("  (read-only)")
+	}
+	hint := fmt.Sprintf(" %s: help ", b.app.keyHint(actionHelp))
+	if b.x+len(hint) < b.app.screenWidth {
+		b.text(padRight("", b.app.screenWidth-b.x-len(hint)))
+		b.markPosition()
+		b.text(hint)
+		b.setTarget(actionHelp)
+	}
 	b.newLine()
 }
 
 func (b *builder) renderBreadcrumbs() {
-	b.setStyle(styleBreadcrumbs)
+	b.setStyle(b.theme.breadcrumbs)
 	b.app.targets = b.app.targets[:0]
 
 	path := b.app.curFolder.fullPath()
@@ -69,51 +90,47 @@ func (b *builder) renderBreadcrumbs() {
 		b.setTarget(selectFolder{path: selectPath})
 	}
 
+	if b.app.search != "" && b.app.searchFolder == b.app.curFolder {
+		b.text(fmt.Sprintf("   Search: %s", b.app.search))
+	}
+	if b.app.dupsOnly {
+		b.text("   Duplicates Only")
+	}
+	if b.app.filter.active() {
+		b.text(fmt.Sprintf("   Filter: %s", b.app.filter.text))
+	}
 	b.newLine()
 }
 
 func (b *builder) renderFolder() {
-	document := "     Document" + b.app.curFolder.sortIndicator(sortByName)
-	modified := "Date Modified" + b.app.curFolder.sortIndicator(sortByTime)
-	size := "Size" + b.app.curFolder.sortIndicator(sortBySize)
-	b.setStyle(styleFolderHeader)
+	shown, nameWidth := b.app.folderColumns()
+	b.setStyle(b.theme.folderHeader)
 
 	b.markPosition()
-	b.text(padRight(document, b.app.screenWidth-39))
+	b.text(padRight("     "+columns[sortByName].title+b.app.curFolder.sortIndicator(sortByName), nameWidth+5))
 	b.setTarget(sortCmd{sortByName})
 
-	b.markPosition()
-	b.text(padRight(modified, 20))
-	b.setTarget(sortCmd{sortByTime})
-
-	b.markPosition()
-	b.text(padLeft(size, 18))
-	b.setTarget(sortCmd{sortBySize})
+	for _, def := range shown {
+		b.markPosition()
+		b.text(def.cell(def.title + b.app.curFolder.sortIndicator(def.column)))
+		b.setTarget(sortCmd{def.column})
+	}
 	b.newLine()
 
 	folder := b.app.curFolder
-	for i := range b.app.screenHeight - 4 {
-		if i+folder.offsetIdx >= len(folder.children) {
-			b.setStyle(styleFile)
+	children := b.app.children(folder)
+	for i := range b.app.folderHeight() {
+		if i+folder.offsetIdx >= len(children) {
+			b.setStyle(b.theme.file)
 			b.newLine()
 		} else {
 			b.markPosition()
-			file := folder.children[i+folder.offsetIdx]
-			if b.app.curFolder.selectedIdx == i+b.app.curFolder.offsetIdx {
-				if file.dups > 0 {
-					b.setStyle(styleFileDupSelected)
-				} else {
-					b.setStyle(styleFileSelected)
-				}
-			} else {
-				b.setStyle(styleFile)
-				if file.dups > 0 {
-					b.setStyle(styleFileDup)
-				} else {
-					b.setStyle(styleFile)
-				}
-			}
-			if file.dups > 0 {
+			file := children[i+folder.offsetIdx]
+			protected := b.app.protected.Contains(file.pathString())
+			b.setRowStyle(b.app.curFolder.selectedIdx == i+b.app.curFolder.offsetIdx, file.dups > 0, protected)
+			if file.folder != nil && len(b.app.copiesOf(file)) > 1 {
+				b.text(" = ")
+			} else if file.dups > 0 {
 				if file.folder != nil {
 					b.text(" D ")
 				} else {
@@ -122,45 +139,81 @@ func (b *builder) renderFolder() {
 			} else {
 				b.text("   ")
 			}
-			if file.folder == nil {
-				b.text("  ")
-			} else {
+			if file.folder != nil {
 				b.text("▶ ")
+			} else if b.app.isMarked(file) {
+				b.text("✔ ")
+			} else if protected && b.theme.markers {
+				b.text("◆ ")
+			} else {
+				b.text("  ")
+			}
+			b.text(padRight(file.name, nameWidth))
+			for _, def := range shown {
+				b.text(def.cell(def.value(b.app.shownFile(file))))
 			}
-			b.text(padRight(file.name, b.app.screenWidth-45))
-			b.text(file.modTime.Format(" 2006-01-02 15:04:05"))
-			b.text(formatSize(file.size))
-			b.text(" ")
 			b.setTarget(selectFile{idx: i + folder.offsetIdx})
 			b.newLine()
 		}
 	}
+	b.renderPanel()
 }
 
 func (b *builder) renderStatusLine() {
-	b.setStyle(styleArchive)
+	if b.app.prompt != nil {
+		b.renderPrompt()
+		return
+	}
+	b.setStyle(b.theme.archive)
 	switch b.app.state {
 	case archiveScanning:
+		progress := b.app.scanProgress
 		b.text(" Scanning ")
+		b.text(fmt.Sprintf(" Folders %s  Files %s  Size %s ",
+			formatCount(progress.Dirs), formatCount(progress.Files), formatBytes(progress.Bytes)))
+		b.renderNotes()
 		b.text(padRight("", b.app.screenWidth-b.x))
 	case archiveHashing:
-		b.text(" Hashing ")
-		b.setStyle(styleProgressBar)
-		b.text(b.progressBar(b.app.hashed, b.app.hashing, b.app.screenWidth-10))
-		b.setStyle(styleArchive)
+		if b.app.paused {
+			b.text(" Paused ")
+		} else {
+			b.text(" Hashing ")
+		}
+		b.text(b.app.hashStatus())
+		b.renderNotes()
+		b.text(" ")
+		b.setStyle(b.theme.progressBar)
+		b.text(b.progressBar(b.app.hashedBytes, b.app.hashingBytes, max(b.app.screenWidth-b.x-1, 0)))
+		b.setStyle(b.theme.archive)
 		b.text(" ")
 	case archiveReady:
 		if b.app.nDuplicates > 0 {
+			b.markPosition()
 			b.text(fmt.Sprintf(" Duplicates %d ", b.app.nDuplicates))
+			if b.app.nFolderDups > 0 {
+				b.text(fmt.Sprintf(" Identical Folders %d ", b.app.nFolderDups))
+			}
+			b.setTarget(actionGroups)
 		} else {
 			b.text(" All Clear ")
 		}
+		b.renderNotes()
 		b.text(padRight("", b.app.screenWidth-b.x))
 	}
 }
 
+// renderNotes shows the marked files and the last message on the status line.
+func (b *builder) renderNotes() {
+	if len(b.app.marked) > 0 {
+		b.text(fmt.Sprintf(" Marked %d files %s ", len(b.app.marked), formatBytes(b.app.markedSize())))
+	}
+	if b.app.message != "" {
+		b.text(" " + b.app.message + " ")
+	}
+}
+
 func (b *builder) renderTooSmall() string {
-	b.setStyle(styleScreenTooSmall)
+	b.setStyle(b.theme.screenTooSmall)
 	for range b.app.screenHeight / 2 {
 		b.newLine()
 	}
@@ -191,6 +244,11 @@ func (b *builder) setStyle(style lipgloss.Style) {
 func (b *builder) text(texts ...string) {
 	for _, text := range texts {
 		runes := []rune(text)
+		if b.selectMarker && len(runes) > 0 {
+			runes[0] = '>'
+			text = string(runes)
+			b.selectMarker = false
+		}
 		b.x += len(runes)
 		b.builder.WriteString(b.style.Render(text))
 	}
@@ -202,6 +260,7 @@ func (b *builder) newLine() {
 		b.x++
 	}
 	b.builder.WriteString(b.style.Render("\n"))
+	b.selectMarker = false
 	b.x = 0
 	b.y++
 }
@@ -276,6 +335,32 @@ func formatSize(size int) string {
 	return b.String()
 }
 
+func formatCount(count int) string {
+	str := fmt.Sprint(count)
+	b := strings.Builder{}
+	for i, r := range str {
+		if i > 0 && (len(str)-i)%3 == 0 {
+			b.WriteRune(',')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func formatBytes(size int) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	suffix := 0
+	for value >= unit && suffix < 4 {
+		value /= unit
+		suffix++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGTP"[suffix])
+}
+
 func counter(count int) string {
 	if count > 9 {
 		return " * "
@@ -2,7 +2,10 @@ package app
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+
+	"dedup/fs"
 )
 
 func TestPadLeft(t *testing.T) {
@@ -14,3 +17,21 @@ func TestPadRight(t *testing.T) {
 	fmt.Printf("%q\n", padRight("abc", 4))
 	fmt.Printf("%q\n", padRight("abc", 2))
 }
+
+func TestStatusLineNotes(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+	)
+	app.screenWidth, app.screenHeight = 120, 30
+	app.marked[app.findFile([]string{"a", "x"})] = struct{}{}
+	app.message = "Something happened"
+
+	for _, state := range []appState{archiveScanning, archiveHashing, archiveReady} {
+		app.state = state
+		view := app.render()
+		if !strings.Contains(view, "Marked 1 files") || !strings.Contains(view, app.message) {
+			t.Errorf("status line in state %v hides the marks or the message", state)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"dedup/config"
+)
+
+// theme holds the styles of the UI. Themes with markers flag selected,
+// duplicated and protected rows with text, for terminals without colour.
+type theme struct {
+	screenTooSmall   lipgloss.Style
+	archive          lipgloss.Style
+	breadcrumbs      lipgloss.Style
+	file             lipgloss.Style
+	fileDup          lipgloss.Style
+	fileSelected     lipgloss.Style
+	fileDupSelected  lipgloss.Style
+	fileProtected    lipgloss.Style
+	fileProtSelected lipgloss.Style
+	folderHeader     lipgloss.Style
+	progressBar      lipgloss.Style
+	markers          bool
+}
+
+const monoTheme = "mono"
+
+var themes = map[string]func() *theme{
+	"default": func() *theme {
+		return &theme{
+			screenTooSmall:   colors("#7D56F4", "9").Bold(true),
+			archive:          colors("226", "0").Bold(true).Italic(true),
+			breadcrumbs:      colors("231", "17").Bold(true),
+			file:             colors("231", "17"),
+			fileDup:          colors("196", "17"),
+			fileSelected:     colors("231", "19"),
+			fileDupSelected:  colors("196", "19"),
+			fileProtected:    colors("46", "17"),
+			fileProtSelected: colors("46", "19"),
+			folderHeader:     colors("255", "243").Bold(true),
+			progressBar:      colors("231", "33").Bold(true),
+		}
+	},
+	"light": func() *theme {
+		return &theme{
+			screenTooSmall:   colors("231", "124").Bold(true),
+			archive:          colors("18", "252").Bold(true).Italic(true),
+			breadcrumbs:      colors("18", "189").Bold(true),
+			file:             colors("235", "231"),
+			fileDup:          colors("166", "231").Bold(true),
+			fileSelected:     colors("235", "153"),
+			fileDupSelected:  colors("166", "153").Bold(true),
+			fileProtected:    colors("25", "231").Underline(true),
+			fileProtSelected: colors("25", "153").Underline(true),
+			folderHeader:     colors("231", "245").Bold(true),
+			progressBar:      colors("231", "25").Bold(true),
+		}
+	},
+	"high-contrast": func() *theme {
+		return &theme{
+			screenTooSmall:   colors("16", "226").Bold(true),
+			archive:          colors("226", "16").Bold(true),
+			breadcrumbs:      colors("231", "16").Bold(true).Underline(true),
+			file:             colors("231", "16"),
+			fileDup:          colors("226", "16").Bold(true),
+			fileSelected:     colors("16", "231"),
+			fileDupSelected:  colors("16", "226").Bold(true),
+			fileProtected:    colors("51", "16").Underline(true),
+			fileProtSelected: colors("16", "51").Underline(true),
+			folderHeader:     colors("16", "250").Bold(true),
+			progressBar:      colors("16", "231").Bold(true),
+			markers:          true,
+		}
+	},
+	monoTheme: func() *theme {
+		plain := lipgloss.NewStyle()
+		return &theme{
+			screenTooSmall:   plain.Bold(true),
+			archive:          plain.Bold(true),
+			breadcrumbs:      plain.Bold(true),
+			file:             plain,
+			fileDup:          plain.Bold(true),
+			fileSelected:     plain.Reverse(true),
+			fileDupSelected:  plain.Bold(true).Reverse(true),
+			fileProtected:    plain.Underline(true),
+			fileProtSelected: plain.Underline(true).Reverse(true),
+			folderHeader:     plain.Bold(true).Underline(true),
+			progressBar:      plain.Reverse(true),
+			markers:          true,
+		}
+	},
+}
+
+func colors(foreground, background string) lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(foreground)).Background(lipgloss.Color(background))
+}
+
+// newTheme returns the named theme with overrides applied. An empty name
+// means the default theme, or the monochrome one when NO_COLOR is set.
+func newTheme(name string, overrides map[string]config.Color) (*theme, error) {
+	if name == "" && os.Getenv("NO_COLOR") != "" {
+		name = monoTheme
+	}
+	if name == "" {
+		name = "default"
+	}
+	themeFunc, ok := themes[name]
+	if !ok {
+		names := make([]string, 0, len(themes))
+		for name := range themes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("unknown theme %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	t := themeFunc()
+	styles := t.styles()
+	for name, color := range overrides {
+		style, ok := styles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown style %q in colors", name)
+		}
+		if color.Foreground != "" {
+			*style = style.Foreground(lipgloss.Color(color.Foreground))
+		}
+		if color.Background != "" {
+			*style = style.Background(lipgloss.Color(color.Background))
+		}
+	}
+	return t, nil
+}
+
+// styles names the styles for colour overrides in the config.
+func (t *theme) styles() map[string]*lipgloss.Style {
+	return map[string]*lipgloss.Style{
+		"screen_too_small":        &t.screenTooSmall,
+		"archive":                 &t.archive,
+		"breadcrumbs":             &t.breadcrumbs,
+		"file":                    &t.file,
+		"file_duplicate":          &t.fileDup,
+		"file_selected":           &t.fileSelected,
+		"file_duplicate_selected": &t.fileDupSelected,
+		"file_protected":          &t.fileProtected,
+		"file_protected_selected": &t.fileProtSelected,
+		"folder_header":           &t.folderHeader,
+		"progress_bar":            &t.progressBar,
+	}
+}
+
+// setRowStyle styles a list row. With markers, the row's first character
+// shows whether it is selected.
+func (b *builder) setRowStyle(selected, dup, protected bool) {
+	t := b.theme
+	switch {
+	case protected && selected:
+		b.setStyle(t.fileProtSelected)
+	case protected:
+		b.setStyle(t.fileProtected)
+	case dup && selected:
+		b.setStyle(t.fileDupSelected)
+	case dup:
+		b.setStyle(t.fileDup)
+	case selected:
+		b.setStyle(t.fileSelected)
+	default:
+		b.setStyle(t.file)
+	}
+	b.selectMarker = t.markers && selected
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"dedup/config"
+)
+
+func TestThemes(t *testing.T) {
+	if _, err := newTheme("solarized", nil); err == nil {
+		t.Error("unknown theme accepted")
+	}
+	if _, err := newTheme("", map[string]config.Color{"files": {Foreground: "1"}}); err == nil {
+		t.Error("unknown style accepted")
+	}
+	theme, err := newTheme("light", map[string]config.Color{"file_duplicate": {Foreground: "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme.fileDup.GetForeground() != lipgloss.Color("1") || theme.markers {
+		t.Errorf("override not applied: %v", theme.fileDup.GetForeground())
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	theme, err = newTheme("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !theme.markers {
+		t.Error("NO_COLOR did not select the monochrome theme")
+	}
+	if theme, _ = newTheme("default", nil); theme.markers {
+		t.Error("NO_COLOR overrode a configured theme")
+	}
+}
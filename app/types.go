@@ -1,6 +1,7 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
@@ -15,10 +16,24 @@ type (
 		rootFolder  *file
 		curFolder   *file
 		byHash      map[string][]*file
+		byFolder    map[string][]*file
+		dirty       map[*file]bool
+		duplicated  map[string]struct{}
+		bySignature map[string][]*file
+		unsigned    map[*file]struct{}
+		regroup     map[string]struct{}
 		nDuplicates int
+		nFolderDups int
 		hashing     int
 		hashed      int
-		state       appState
+
+		hashingBytes int
+		hashedBytes  int
+		hashStart    time.Time
+		lastReport   time.Time
+		paused       bool
+		pausedAt     time.Time
+		state        appState
 
 		targets       []target
 		screenWidth   int
@@ -26,7 +41,47 @@ type (
 		lastClickTime time.Time
 		lastX, lastY  int
 
-		events events
+		screen    appScreen
+		groupList groupList
+		group     *group
+		members   cursor
+		panel     copiesPanel
+		overlaps  overlapList
+		overlap   *overlap
+		diffRows  []diffRow
+		diff      cursor
+		marked    map[*file]struct{}
+		message   string
+
+		filter       filter
+		dupsOnly     bool
+		search       string
+		searchFolder *file
+		visible      map[*file]files
+		matching     map[*file]bool
+		shown        map[*file]*file
+		prompt       *prompt
+		findText     string
+		results      files
+		resultCursor cursor
+		confirmation *confirmation
+		skipConfirm  bool
+		protected    fs.Protected
+		readOnly     bool
+		keys         keymap
+		dialogKeys   keymap
+		theme        *theme
+		columns      []*columnDef
+		help         bool
+		helpCursor   cursor
+
+		scanProgress fs.ScanProgress
+		scanIndex    map[string]*file
+		scanSeen     map[*file]struct{}
+
+		events   events
+		headless bool
+		quitting bool
 	}
 
 	file struct {
@@ -34,6 +89,7 @@ type (
 		size    int
 		modTime time.Time
 		hash    string
+		inode   uint64
 		parent  *file
 		dups    int
 		*folder
@@ -47,10 +103,20 @@ type (
 		offsetIdx     int
 		sortColumn    sortColumn
 		sortAscending []bool
+		signature     string
+		wasted        int
 	}
 
 	appState int
 
+	appScreen int
+
+	// cursor tracks the selected row and scroll offset of a list screen.
+	cursor struct {
+		selectedIdx int
+		offsetIdx   int
+	}
+
 	sortColumn int
 
 	target struct {
@@ -78,10 +144,26 @@ const (
 	archiveReady
 )
 
+const (
+	screenFolder appScreen = iota
+	screenGroups
+	screenGroup
+	screenOverlaps
+	screenOverlap
+	screenResults
+)
+
 const (
 	sortByName sortColumn = iota
 	sortByTime
 	sortBySize
+	sortByHash
+	sortByDups
+	sortByWasted
+	sortByExt
+	sortByInode
+	sortByStatus
+	sortByItems
 )
 
 func (f *file) String() string {
@@ -124,7 +206,7 @@ func (parent *file) getChild(sub string) *file {
 			name:   sub,
 			parent: parent,
 			folder: &folder{
-				sortAscending: []bool{true, true, true},
+				sortAscending: newSortOrder(),
 			},
 		}
 		parent.children = append(parent.children, child)
@@ -132,39 +214,13 @@ func (parent *file) getChild(sub string) *file {
 	return child
 }
 
+// analyze refreshes everything derived from the duplicate index:
+// identical folders and the open group and overlap screens.
 func (app *app) analyze() {
-	byHash := map[string][]*file{}
-	app.analyzeRec(byHash, app.rootFolder)
-	dups := map[string]struct{}{}
-	for hash, files := range byHash {
-		if len(files) > 1 {
-			dups[hash] = struct{}{}
-		}
-	}
-	for hash := range dups {
-		files := byHash[hash]
-		if _, ok := dups[hash]; ok {
-			app.byHash[hash] = files
-			for _, file := range files {
-				file.dups = len(files)
-			}
-		}
-	}
-	app.nDuplicates = len(dups)
-	app.rootFolder.updateMetas()
-}
-
-func (app *app) analyzeRec(byHash map[string][]*file, file *file) {
-	if file.folder != nil {
-		for _, child := range file.children {
-			app.analyzeRec(byHash, child)
-		}
-	} else {
-		file.dups = 0
-		files := byHash[file.hash]
-		files = append(files, file)
-		byHash[file.hash] = files
-	}
+	app.flushDirty()
+	app.analyzeFolders()
+	app.refreshGroups()
+	app.refreshOverlaps()
 }
 
 func (app *app) findFile(path []string) *file {
@@ -211,30 +267,454 @@ func (f *file) fullPath() (result []string) {
 	return result
 }
 
-func (folder *file) updateMetas() {
-	folder.size = 0
-	folder.modTime = time.Time{}
-	folder.dups = 0
+func (folder *file) updateMeta(meta *file) {
+	folder.size += meta.size
+	folder.dups += meta.dups
+	folder.wasted += meta.wastedSize()
+	if folder.modTime.Before(meta.modTime) {
+		folder.modTime = meta.modTime
+	}
+}
+
+func (app *app) addMeta(meta fs.FileMeta) *file {
+	path, name := parseName(meta.Path)
+	incoming := &file{
+		name:    name,
+		size:    meta.Size,
+		modTime: meta.ModTime,
+		hash:    meta.Hash,
+		inode:   meta.Inode,
+	}
+	folder := app.getFile(path)
+	folder.children = append(folder.children, incoming)
+	incoming.parent = folder
+	app.indexFile(incoming)
+	app.markDirty(incoming, true)
+	if meta.Hash == "" {
+		app.hashing++
+		app.hashingBytes += meta.Size
+	}
+	return incoming
+}
+
+// mergeMetas applies a batch of a scan listing to the tree: new or changed
+// files are updated in place and every listed file is remembered, so that
+// sweep can remove the files the scan did not see.
+func (app *app) mergeMetas(metas fs.FileMetas) {
+	selected := app.selectedFile()
 
+	if app.scanIndex == nil {
+		app.scanIndex = map[string]*file{}
+		app.scanSeen = map[*file]struct{}{}
+		app.rootFolder.collectFiles(app.scanIndex)
+	}
+
+	for _, meta := range metas {
+		file := app.scanIndex[meta.Path]
+		if file == nil {
+			file = app.addMeta(meta)
+		} else if file.size != meta.Size || !file.modTime.Equal(meta.ModTime) || file.hash != meta.Hash || file.inode != meta.Inode {
+			app.updateMeta(file, meta)
+		}
+		app.scanSeen[file] = struct{}{}
+	}
+
+	app.flushDirty()
+	app.selectFile(selected)
+}
+
+// sweep completes a scan by removing the files missing from its listing.
+func (app *app) sweep() {
+	selected := app.selectedFile()
+	for _, file := range app.scanIndex {
+		if _, ok := app.scanSeen[file]; !ok {
+			app.removeFile(file)
+		}
+	}
+	app.scanIndex = nil
+	app.scanSeen = nil
+	app.flushDirty()
+	app.selectFile(selected)
+}
+
+func (folder *file) collectFiles(result map[string]*file) {
 	for _, child := range folder.children {
 		if child.folder != nil {
-			child.updateMetas()
+			child.collectFiles(result)
+		} else {
+			result[strings.Join(child.fullPath(), "/")] = child
 		}
-		folder.updateMeta(child)
 	}
 }
 
-func (folder *file) updateMeta(meta *file) {
-	folder.size += meta.size
-	folder.dups += meta.dups
-	if folder.modTime.Before(meta.modTime) {
-		folder.modTime = meta.modTime
+func (app *app) updateMeta(file *file, meta fs.FileMeta) {
+	app.unindexFile(file)
+	file.size = meta.Size
+	file.modTime = meta.ModTime
+	file.hash = meta.Hash
+	file.inode = meta.Inode
+	app.indexFile(file)
+	app.markDirty(file, true)
+	if meta.Hash == "" {
+		app.hashing++
+		app.hashingBytes += meta.Size
+	}
+}
+
+// removeFile detaches file from the tree together with any folders
+// left empty by its removal.
+func (app *app) removeFile(file *file) {
+	var removed files
+	file.collectTree(&removed)
+	for _, file := range removed {
+		delete(app.marked, file)
+		app.unindexFile(file)
+	}
+
+	for file != app.rootFolder {
+		parent := file.parent
+		parent.deleteFile(file)
+		app.unsignTree(file)
+		if len(parent.children) > 0 {
+			break
+		}
+		file = parent
+	}
+	if file == app.rootFolder {
+		app.dirty[file] = true
+	} else {
+		app.markDirty(file, true)
+	}
+	for !app.isAttached(app.curFolder) {
+		app.curFolder = app.curFolder.parent
+	}
+	results := app.results[:0]
+	for _, result := range app.results {
+		if app.isAttached(result) {
+			results = append(results, result)
+		}
+	}
+	app.results = results
+}
+
+func (app *app) isAttached(file *file) bool {
+	for ; file.parent != nil; file = file.parent {
+		if !slices.Contains(file.parent.children, file) {
+			return false
+		}
 	}
+	return file == app.rootFolder
 }
 
-func (app *app) deleteFile(file *file) {
-	folder := app.findFile(file.path())
-	folder.deleteFile(file)
+func (app *app) selectedFile() *file {
+	children := app.children(app.curFolder)
+	if app.curFolder.selectedIdx < len(children) {
+		return children[app.curFolder.selectedIdx]
+	}
+	return nil
+}
+
+func (app *app) selectFile(file *file) {
+	for idx, child := range app.children(app.curFolder) {
+		if child == file {
+			app.curFolder.selectedIdx = idx
+			return
+		}
+	}
+}
+
+// nextDuplicate selects the next duplicated file after the selected one, or the previous
+// one when backward, walking the tree depth-first in the order the folders are sorted.
+func (app *app) nextDuplicate(backward bool) {
+	var order files
+	var walk func(folder *file)
+	walk = func(folder *file) {
+		for _, child := range app.children(folder) {
+			order = append(order, child)
+			if child.folder != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(app.rootFolder)
+
+	start := slices.Index(order, app.selectedFile())
+	if start < 0 {
+		start = slices.Index(order, app.curFolder)
+	}
+	step := 1
+	if backward {
+		step = -1
+		if start < 0 {
+			start = 0
+		}
+	}
+	for i := 1; i <= len(order); i++ {
+		file := order[((start+i*step)%len(order)+len(order))%len(order)]
+		if file.folder == nil && file.dups > 0 {
+			app.curFolder = file.parent
+			app.selectFile(file)
+			folder := app.curFolder
+			if folder.selectedIdx < folder.offsetIdx || folder.selectedIdx >= folder.offsetIdx+app.folderHeight() {
+				folder.offsetIdx = folder.selectedIdx - app.folderHeight()/2
+			}
+			return
+		}
+	}
+	app.message = "No duplicates"
+}
+
+func (app *app) rescan() {
+	if app.state != archiveReady {
+		app.report("archive is busy, rescan ignored")
+		return
+	}
+	app.report("rescanning %s", app.fs.Root())
+	app.state = archiveScanning
+	app.scanProgress = fs.ScanProgress{}
+	app.resetHashProgress()
+	app.fs.Rescan(app.events)
+}
+
+func (app *app) setPaused(paused bool) {
+	if app.paused == paused {
+		return
+	}
+	app.paused = paused
+	app.fs.SetPaused(paused)
+	if paused {
+		app.pausedAt = time.Now()
+		app.report("hashing paused")
+	} else {
+		app.hashStart = app.hashStart.Add(time.Since(app.pausedAt))
+		app.report("hashing resumed")
+	}
+}
+
+func (app *app) resetHashProgress() {
+	app.hashing = 0
+	app.hashed = 0
+	app.hashingBytes = 0
+	app.hashedBytes = 0
+}
+
+// hashRate returns hashing throughput in bytes per second
+// and the estimated time to hash the remaining bytes.
+func (app *app) hashRate() (float64, time.Duration) {
+	elapsed := time.Since(app.hashStart).Seconds()
+	if elapsed <= 0 || app.hashedBytes == 0 {
+		return 0, 0
+	}
+	rate := float64(app.hashedBytes) / elapsed
+	remaining := max(app.hashingBytes-app.hashedBytes, 0)
+	return rate, time.Duration(float64(remaining) / rate * float64(time.Second))
+}
+
+func (app *app) hashStatus() string {
+	rate, eta := app.hashRate()
+	return fmt.Sprintf("%s / %s  %s/s  ETA %s",
+		formatBytes(app.hashedBytes), formatBytes(app.hashingBytes), formatBytes(int(rate)), eta.Round(time.Second))
+}
+
+// keepFile removes every other copy of a file or an identical folder from the archive.
+// Protected copies are always kept.
+func (app *app) keepFile(keep *file) {
+	if app.folderBusy(keep) {
+		return
+	}
+	left := 0
+	var lastErr error
+	for _, dup := range slices.Clone(app.copiesOf(keep)) {
+		if dup == keep {
+			continue
+		}
+		if !isCopy(dup, keep) {
+			var remaining files
+			dup.collectTree(&remaining)
+			left += len(remaining)
+			lastErr = fmt.Errorf("%q is no longer a copy of %q", dup.pathString(), keep.pathString())
+			continue
+		}
+		n, err := app.removeCopy(dup, keep)
+		left += n
+		if err != nil {
+			lastErr = err
+		}
+	}
+	app.analyze()
+	if lastErr != nil && left == 0 {
+		app.message = fmt.Sprintf("Removed the copies, but %v", lastErr)
+	} else if lastErr != nil {
+		app.message = fmt.Sprintf("Left %d files in place: %v", left, lastErr)
+	} else if left > 0 {
+		app.message = fmt.Sprintf("Kept %d protected files", left)
+	}
+}
+
+// folderBusy reports whether f is a folder while the archive is still being
+// scanned or hashed, when folder signatures may not match the tree.
+func (app *app) folderBusy(f *file) bool {
+	if f.folder != nil && app.state != archiveReady {
+		app.message = "Identical folders can be resolved once hashing is done"
+		return true
+	}
+	return false
+}
+
+// isCopy reports whether dup holds the same content as keep. Folder
+// signatures are recomputed, as the tree may have changed since the analysis.
+func isCopy(dup, keep *file) bool {
+	if dup.folder == nil || keep.folder == nil {
+		return dup.folder == nil && keep.folder == nil && dup.hash != "" && dup.hash == keep.hash
+	}
+	signature := keep.contentSignature()
+	return signature != "" && dup.contentSignature() == signature
+}
+
+// removeCopy removes dup, a copy of keep, from the archive and from the tree.
+// Folders are removed file by file against their counterparts in keep;
+// files whose hash differs from their counterpart are left in place.
+// It returns the number of files left in place and the last error.
+func (app *app) removeCopy(dup, keep *file) (left int, err error) {
+	if dup.folder == nil {
+		if !app.removable(dup, keep) {
+			if app.isProtected(dup) {
+				return 1, nil
+			}
+			return 1, fmt.Errorf("%q is no longer a copy of %q", dup.pathString(), keep.pathString())
+		}
+		err := app.fs.Remove(dup.meta(), keep.meta())
+		if err != nil && !errors.Is(err, fs.ErrNotAudited) {
+			app.applyChange(err)
+			return 1, err
+		}
+		app.removeFile(dup)
+		return 0, err
+	}
+
+	for _, child := range slices.Clone(dup.children) {
+		original := keep.findChild(child.name)
+		if original == nil {
+			left++
+			err = fmt.Errorf("%q has no counterpart in %q", child.pathString(), keep.pathString())
+			continue
+		}
+		n, childErr := app.removeCopy(child, original)
+		left += n
+		if childErr != nil {
+			err = childErr
+		}
+	}
+	if left == 0 {
+		// The folder is empty now unless it holds files the scan ignores.
+		_ = app.fs.Remove(dup.meta(), keep.meta())
+	}
+	return left, err
+}
+
+// removable reports whether removeCopy removes the file dup as a copy of keep.
+func (app *app) removable(dup, keep *file) bool {
+	return !app.isProtected(dup) && isCopy(dup, keep)
+}
+
+// collectCopies collects the files of dup that removeCopy removes as copies
+// of keep, matching them by name in the same way.
+func (app *app) collectCopies(dup, keep *file, result *files) {
+	if dup.folder == nil {
+		if app.removable(dup, keep) {
+			*result = append(*result, dup)
+		}
+		return
+	}
+	for _, child := range dup.children {
+		if original := keep.findChild(child.name); original != nil {
+			app.collectCopies(child, original, result)
+		}
+	}
+}
+
+// isProtected reports whether f lies under a protected path,
+// or for a folder, whether it holds one.
+func (app *app) isProtected(f *file) bool {
+	if len(app.protected) == 0 {
+		return false
+	}
+	path := f.pathString()
+	return app.protected.Contains(path) || f.folder != nil && app.protected.Within(path)
+}
+
+// applyChange updates the tree with the current state of a file
+// that an fs operation found changed since it was scanned.
+func (app *app) applyChange(err error) {
+	var changed *fs.ChangedError
+	if !errors.As(err, &changed) {
+		return
+	}
+	file := app.findFile(parsePath(changed.File.Path))
+	if file == nil || file.folder != nil {
+		return
+	}
+	if changed.Removed {
+		app.removeFile(file)
+	} else {
+		app.updateMeta(file, changed.File)
+	}
+}
+
+func (f *file) meta() fs.FileMeta {
+	return fs.FileMeta{
+		Path:    f.pathString(),
+		Size:    f.size,
+		ModTime: f.modTime,
+		Hash:    f.hash,
+		Inode:   f.inode,
+	}
+}
+
+func (c *cursor) handleAction(a action, size, height int) bool {
+	switch a {
+	case actionUp:
+		c.selectedIdx--
+	case actionDown:
+		c.selectedIdx++
+	case actionPageUp:
+		c.selectedIdx -= height
+		c.offsetIdx -= height
+	case actionPageDown:
+		c.selectedIdx += height
+		c.offsetIdx += height
+	case actionHome:
+		c.selectedIdx = 0
+		c.offsetIdx = 0
+	case actionEnd:
+		c.selectedIdx = size - 1
+		c.offsetIdx = size - height
+	default:
+		return false
+	}
+	c.clamp(size, height)
+	if c.selectedIdx < c.offsetIdx {
+		c.offsetIdx = c.selectedIdx
+	}
+	if c.selectedIdx >= c.offsetIdx+height {
+		c.offsetIdx = c.selectedIdx - height + 1
+	}
+	return true
+}
+
+func (c *cursor) clamp(size, height int) {
+	if c.selectedIdx >= size {
+		c.selectedIdx = size - 1
+	}
+	if c.selectedIdx < 0 {
+		c.selectedIdx = 0
+	}
+	if c.offsetIdx >= size-height {
+		c.offsetIdx = size - height
+	}
+	if c.offsetIdx < 0 {
+		c.offsetIdx = 0
+	}
 }
 
 func (folder *folder) deleteFile(file *file) {
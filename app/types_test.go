@@ -0,0 +1,236 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"dedup/fs"
+	"dedup/fs/mockfs"
+)
+
+// testTime is the modification time of the files of test archives.
+var testTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestApp() *app {
+	rootFolder := &file{
+		folder: &folder{
+			sortAscending: newSortOrder(),
+		},
+	}
+	keys, dialogKeys, _ := newKeymaps(nil)
+	theme, _ := newTheme("", nil)
+	columns, _ := newColumns(nil)
+	return &app{
+		theme:       theme,
+		columns:     columns,
+		keys:        keys,
+		dialogKeys:  dialogKeys,
+		rootFolder:  rootFolder,
+		curFolder:   rootFolder,
+		byHash:      map[string][]*file{},
+		byFolder:    map[string][]*file{},
+		dirty:       map[*file]bool{},
+		duplicated:  map[string]struct{}{},
+		bySignature: map[string][]*file{},
+		unsigned:    map[*file]struct{}{},
+		regroup:     map[string]struct{}{},
+		groupList:   newGroupList(),
+		marked:      map[*file]struct{}{},
+		visible:     map[*file]files{},
+		matching:    map[*file]bool{},
+		shown:       map[*file]*file{},
+	}
+}
+
+// newTestArchive returns a test app on a mock archive holding metas, scanned,
+// hashed and analyzed. Metas without a modification time get testTime.
+func newTestArchive(metas ...fs.FileMeta) *app {
+	app := newTestApp()
+	app.fs = mockfs.New("origin")
+	for i := range metas {
+		if metas[i].ModTime.IsZero() {
+			metas[i].ModTime = testTime
+		}
+	}
+	app.mergeMetas(metas)
+	app.sweep()
+	app.analyze()
+	app.state = archiveReady
+	return app
+}
+
+// names returns the names of the children of folder shown in the tree.
+func (app *app) names(folder *file) (result []string) {
+	for _, child := range app.children(folder) {
+		result = append(result, child.name)
+	}
+	return result
+}
+
+func TestMergeMetasAndSweep(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "a/y", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "b/z", Size: 3, Hash: "h3"},
+	)
+	x := app.findFile([]string{"a", "x"})
+	app.curFolder = app.findFile([]string{"a"})
+	app.curFolder.selectedIdx = 0
+
+	app.mergeMetas(fs.FileMetas{
+		{Path: "a/w", Size: 4, ModTime: testTime},
+	})
+	app.mergeMetas(fs.FileMetas{
+		{Path: "a/x", Size: 1, ModTime: testTime, Hash: "h1"},
+		{Path: "a/y", Size: 5, ModTime: testTime},
+	})
+	app.sweep()
+
+	if app.findFile([]string{"a", "x"}) != x {
+		t.Error("unchanged file was replaced")
+	}
+	if y := app.findFile([]string{"a", "y"}); y == nil || y.size != 5 || y.hash != "" {
+		t.Errorf("changed file was not updated: %v", y)
+	}
+	if app.findFile([]string{"b"}) != nil {
+		t.Error("removed folder is still in the tree")
+	}
+	if app.selectedFile() != x {
+		t.Errorf("selection was not preserved: %v", app.selectedFile())
+	}
+	if app.hashing != 2 {
+		t.Errorf("expected 2 files to hash, got %d", app.hashing)
+	}
+}
+
+func TestNextDuplicate(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "a/y", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "b/c/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "z", Size: 3, Hash: "h3"},
+	)
+	app.screenHeight = 24
+
+	var visited []string
+	for range 3 {
+		app.nextDuplicate(false)
+		visited = append(visited, strings.Join(app.selectedFile().fullPath(), "/"))
+	}
+	if want := []string{"a/x", "b/c/x", "a/x"}; !slices.Equal(visited, want) {
+		t.Errorf("forward: got %v, want %v", visited, want)
+	}
+
+	app.nextDuplicate(true)
+	if got := app.selectedFile(); got != app.findFile([]string{"b", "c", "x"}) {
+		t.Errorf("backward wrap: got %v", got)
+	}
+}
+
+// changedFS reports every file in changed as modified since the scan.
+type changedFS struct {
+	*mockfs.FS
+	changed map[string]fs.FileMeta
+}
+
+func (fsys changedFS) Remove(file, original fs.FileMeta) error {
+	if meta, ok := fsys.changed[file.Path]; ok {
+		return &fs.ChangedError{File: meta}
+	}
+	return fsys.FS.Remove(file, original)
+}
+
+func TestKeepFileSkipsChangedCopies(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "c/x", Size: 1, Hash: "h1"},
+	)
+	app.fs = changedFS{
+		FS:      mockfs.New("origin"),
+		changed: map[string]fs.FileMeta{"b/x": {Path: "b/x", Size: 2, ModTime: testTime, Hash: "h2"}},
+	}
+
+	app.keepFile(app.findFile([]string{"a", "x"}))
+	if app.findFile([]string{"c", "x"}) != nil {
+		t.Error("unchanged copy was not removed")
+	}
+	changed := app.findFile([]string{"b", "x"})
+	if changed == nil || changed.hash != "h2" || changed.size != 2 {
+		t.Fatalf("changed copy not refreshed: %v", changed)
+	}
+	if app.nDuplicates != 0 || app.message == "" {
+		t.Errorf("expected no duplicates and a message, got %d, %q", app.nDuplicates, app.message)
+	}
+}
+
+func TestProtectedCopiesAreKept(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "master/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "copy/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "keep/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "master/y", Size: 2, Hash: "h2"},
+		fs.FileMeta{Path: "copy/y", Size: 2, Hash: "h2"},
+	)
+	app.protected = fs.NewProtected("/archive", []string{"/archive/master", "other/../keep"})
+
+	app.keepFile(app.findFile([]string{"copy", "x"}))
+	if app.findFile([]string{"master", "x"}) == nil || app.findFile([]string{"keep", "x"}) == nil {
+		t.Error("protected copy was removed")
+	}
+
+	app.toggleMark(app.findFile([]string{"master", "y"}))
+	app.runBatch(batchDelete)
+	if app.confirmation != nil || app.findFile([]string{"master", "y"}) == nil {
+		t.Error("batch delete offered to remove a protected file")
+	}
+}
+
+func TestReadOnlyRefusesRemoval(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/y", Size: 2, Hash: "h2"},
+	)
+	app.readOnly = true
+
+	app.confirmKeep(app.findFile([]string{"a", "x"}))
+	if app.confirmation != nil || app.message != "Archive is opened read-only" {
+		t.Errorf("read-only archive asked to remove copies: %q", app.message)
+	}
+	app.skipConfirm = true
+	app.toggleMark(app.findFile([]string{"b", "y"}))
+	app.runBatch(batchDelete)
+	if app.findFile([]string{"b", "x"}) == nil || app.findFile([]string{"b", "y"}) == nil {
+		t.Error("removed files from a read-only archive")
+	}
+}
+
+// unauditedFS makes every change without recording it in the audit log.
+type unauditedFS struct {
+	*mockfs.FS
+}
+
+func (fsys unauditedFS) Remove(file, original fs.FileMeta) error {
+	_ = fsys.FS.Remove(file, original)
+	return fmt.Errorf("%w: disk full", fs.ErrNotAudited)
+}
+
+func TestUnauditedChangesAreApplied(t *testing.T) {
+	app := newTestArchive(
+		fs.FileMeta{Path: "a/x", Size: 1, Hash: "h1"},
+		fs.FileMeta{Path: "b/x", Size: 1, Hash: "h1"},
+	)
+	app.fs = unauditedFS{mockfs.New("origin")}
+
+	app.keepFile(app.findFile([]string{"a", "x"}))
+	if app.findFile([]string{"b", "x"}) != nil {
+		t.Error("removed copy is still in the tree")
+	}
+	if !strings.Contains(app.message, "audit log") {
+		t.Errorf("message does not mention the audit log: %q", app.message)
+	}
+}
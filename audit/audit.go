@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Operations recorded in the log.
+const (
+	OpRemove = "remove"
+	OpTrash  = "trash"
+	OpLink   = "link"
+)
+
+// Record describes one change made to an archive.
+type Record struct {
+	Time time.Time `json:"time"`
+	Op   string    `json:"op"`
+	Path string    `json:"path"`
+	Size int       `json:"size"`
+	Hash string    `json:"hash"`
+	Kept string    `json:"kept"`
+	User string    `json:"user"`
+}
+
+// Log appends records as JSON lines to a file that is never rewritten.
+type Log struct {
+	path string
+	user string
+	mu   sync.Mutex
+}
+
+// DefaultPath returns the log location inside the archive.
+func DefaultPath(root string) string {
+	return filepath.Join(root, "~~~dedup", "audit.jsonl")
+}
+
+func New(path string) *Log {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	return &Log{path: path, user: name}
+}
+
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append adds a record stamped with the current time and user.
+func (l *Log) Append(record Record) error {
+	record.Time = time.Now().UTC()
+	record.User = l.user
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := l.open()
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Check verifies that records can be appended without creating anything:
+// an existing log must open for writing, and otherwise the nearest existing
+// folder above it must be writable, so that Append can create the log.
+func (l *Log) Check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err == nil {
+		return file.Close()
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	dir := filepath.Dir(l.path)
+	info, err := os.Stat(dir)
+	for errors.Is(err, fs.ErrNotExist) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+		info, err = os.Stat(dir)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "check", Path: dir, Err: unix.ENOTDIR}
+	}
+	if err := unix.Access(dir, unix.W_OK|unix.X_OK); err != nil {
+		return &fs.PathError{Op: "check", Path: dir, Err: err}
+	}
+	return nil
+}
+
+func (l *Log) open() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+}
+
+// Query selects records; empty fields match everything.
+type Query struct {
+	Path  string
+	Op    string
+	Since time.Time
+}
+
+func (q Query) matches(record Record) bool {
+	return (q.Path == "" || strings.Contains(record.Path, q.Path) || strings.Contains(record.Kept, q.Path)) &&
+		(q.Op == "" || record.Op == q.Op) &&
+		!record.Time.Before(q.Since)
+}
+
+// Read returns the records of the log at path that match query, oldest first.
+func Read(path string, query Query) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var result []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		record := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return result, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if query.matches(record) {
+			result = append(result, record)
+		}
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	log := New(filepath.Join(t.TempDir(), "nested", "audit.jsonl"))
+	for _, record := range []Record{
+		{Op: OpRemove, Path: "b/x.jpg", Size: 10, Hash: "h1", Kept: "a/x.jpg"},
+		{Op: OpTrash, Path: "c/y.jpg", Size: 20, Hash: "h2", Kept: "a/y.jpg"},
+		{Op: OpLink, Path: "d/x.jpg", Size: 10, Hash: "h1", Kept: "a/x.jpg"},
+	} {
+		if err := log.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := Read(log.Path(), Query{})
+	if err != nil || len(records) != 3 {
+		t.Fatalf("expected 3 records, got %v, %v", records, err)
+	}
+	if records[0].Time.IsZero() || records[0].Kept != "a/x.jpg" {
+		t.Errorf("record not stamped or incomplete: %+v", records[0])
+	}
+
+	records, _ = Read(log.Path(), Query{Path: "a/x.jpg", Op: OpLink})
+	if len(records) != 1 || records[0].Path != "d/x.jpg" {
+		t.Errorf("query returned %v", records)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	log := New(filepath.Join(dir, "nested", "audit.jsonl"))
+	if err := log.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nested")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("check created the log folder: %v", err)
+	}
+
+	if err := log.Append(Record{Op: OpRemove, Path: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Check(); err != nil {
+		t.Errorf("existing log rejected: %v", err)
+	}
+	if err := New(filepath.Join(log.Path(), "audit.jsonl")).Check(); err == nil {
+		t.Error("log inside a file accepted")
+	}
+
+	if os.Geteuid() != 0 {
+		readOnly := filepath.Join(dir, "read-only")
+		if err := os.Mkdir(readOnly, 0o555); err != nil {
+			t.Fatal(err)
+		}
+		if err := New(filepath.Join(readOnly, "logs", "audit.jsonl")).Check(); err == nil {
+			t.Error("log in a read-only folder accepted")
+		}
+	}
+}
@@ -1,18 +1,51 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 
 	"dedup/app"
+	"dedup/config"
 	"dedup/fs"
 	"dedup/fs/mockfs"
 	"dedup/fs/realfs"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	sim := flag.Bool("sim", false, "run against simulated archive")
+	watch := flag.Bool("watch", false, "watch the archive for changes after the initial scan")
+	headless := flag.Bool("headless", false, "run without the TUI, reading commands from stdin")
+	force := flag.Bool("force", false, "open the archive even if another instance holds its lock")
+	readOnly := flag.Bool("read-only", false, "never change the archive; keep the hash cache in the user cache directory")
+	cache := flag.String("cache", cfg.Cache, "store the hash cache in the \"archive\" or in an \"external\" user cache directory")
+	rate := flag.String("rate", cfg.RateLimit, "limit hashing I/O to this many bytes per second, e.g. 20MB")
+	flag.Parse()
+
+	if flag.Arg(0) == "log" {
+		runLog(cfg, flag.Args()[1:])
+		return
+	}
+
+	rateLimit, err := config.ParseSize(*rate)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	cacheLocation, err := realfs.ParseCacheLocation(*cache)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	logName := os.Getenv("DEDUP_LOG")
 	if logName != "" {
 		logFile, err := os.Create(logName)
@@ -29,20 +62,48 @@ func main() {
 	}
 
 	var fsys fs.FS
-	if len(os.Args) != 2 {
-		fmt.Println("Provide path to an archive")
-		os.Exit(1)
-	}
-	if os.Args[1] == "-sim" {
+	if *sim {
 		fsys = mockfs.New("origin")
 	} else {
-		path, err := realfs.AbsPath(os.Args[1])
+		if flag.NArg() != 1 {
+			fmt.Println("Provide path to an archive")
+			os.Exit(1)
+		}
+		path, err := realfs.AbsPath(flag.Arg(0))
 		if err != nil {
 			log.Printf("Failed to scan archives: %W\n", err)
 			panic(err)
 		}
-		fsys = realfs.New(path)
+		fsys, err = realfs.New(path, realfs.Options{
+			Watch:     *watch,
+			RateLimit: rateLimit,
+			Protected: fs.NewProtected(path, cfg.Protected),
+			ReadOnly:  *readOnly,
+			Cache:     cacheLocation,
+			AuditLog:  cfg.AuditLog,
+			Force:     *force,
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	}
 
-	app.Run(fsys)
+	err = app.Run(fsys, app.Options{
+		Headless:    *headless,
+		SkipConfirm: cfg.SkipConfirm,
+		Protected:   fs.NewProtected(fsys.Root(), cfg.Protected),
+		ReadOnly:    *readOnly,
+		Keys:        cfg.Keys,
+		Theme:       cfg.Theme,
+		Colors:      cfg.Colors,
+		Columns:     cfg.Columns,
+	})
+	if closer, ok := fsys.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dedup/audit"
+	"dedup/config"
+	"dedup/fs/realfs"
+)
+
+// runLog implements "dedup log": it prints the audit log of an archive.
+func runLog(cfg config.Config, args []string) {
+	flags := flag.NewFlagSet("log", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dedup log [flags] [archive]")
+		flags.PrintDefaults()
+	}
+	path := flags.String("path", "", "only show records whose path or kept copy contains this text")
+	op := flags.String("op", "", "only show one operation: remove, trash or link")
+	since := flags.String("since", "", "only show records since this date, e.g. 2025-01-31")
+	_ = flags.Parse(args)
+
+	query := audit.Query{Path: *path, Op: *op}
+	if *since != "" {
+		var err error
+		query.Since, err = time.ParseInLocation(time.DateOnly, *since, time.Local)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	logPath := cfg.AuditLog
+	if flags.NArg() == 1 {
+		root, err := realfs.AbsPath(flags.Arg(0))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if logPath == "" {
+			logPath = audit.DefaultPath(root)
+		}
+	}
+	if logPath == "" {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	records, err := audit.Read(logPath, query)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tOP\tUSER\tSIZE\tPATH\tKEPT\tHASH")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			r.Time.Local().Format(time.DateTime), r.Op, r.User, r.Size, r.Path, r.Kept, r.Hash)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Config struct {
+	// RateLimit caps hashing I/O, e.g. "20MB" per second; empty means unlimited.
+	RateLimit string `json:"rate_limit"`
+
+	// SkipConfirm removes files without showing the confirmation dialog.
+	SkipConfirm bool `json:"skip_confirm"`
+
+	// Protected lists folders or files, absolute or relative to the archive,
+	// that are never removed even when they have copies elsewhere.
+	Protected []string `json:"protected"`
+
+	// Cache is "archive" to keep the hash cache in the archive root,
+	// or "external" to keep it in the user cache directory.
+	Cache string `json:"cache"`
+
+	// AuditLog is the file removals, trash moves and links are recorded in;
+	// empty means ~~~dedup/audit.jsonl in the archive.
+	AuditLog string `json:"audit_log"`
+
+	// Keys replaces the default keys of actions, e.g. {"down": ["j", "down"]}.
+	Keys map[string][]string `json:"keys"`
+
+	// Theme is "default", "light", "high-contrast" or "mono".
+	Theme string `json:"theme"`
+
+	// Colors overrides the colours of theme styles by name,
+	// e.g. {"file_duplicate": {"fg": "208"}}.
+	Colors map[string]Color `json:"colors"`
+
+	// Columns lists the columns of the file list shown after the name:
+	// "modified", "size", "hash", "dups", "wasted", "ext", "inode", "status"
+	// and "items". Columns that don't fit the screen are dropped from the end.
+	Columns []string `json:"columns"`
+}
+
+// Color is a foreground and background colour: an ANSI number or a "#rrggbb" value.
+type Color struct {
+	Foreground string `json:"fg"`
+	Background string `json:"bg"`
+}
+
+// Path returns the location of the config file: $DEDUP_CONFIG if set,
+// otherwise dedup/config.json in the user config directory.
+func Path() (string, error) {
+	if path := os.Getenv("DEDUP_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dedup", "config.json"), nil
+}
+
+// Load reads the config file. A missing file yields the default config.
+func Load() (Config, error) {
+	config := Config{}
+	path, err := Path()
+	if err != nil {
+		return config, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("config %q: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseSize parses sizes like "1500", "64K", "20MB" or "1.5 GiB" into bytes.
+// Unit prefixes are powers of 1024.
+func ParseSize(size string) (int, error) {
+	text := strings.ToUpper(strings.TrimSpace(size))
+	if text == "" {
+		return 0, nil
+	}
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "B"), "I")
+	multiplier := 1
+	if idx := strings.IndexAny(text, "KMGT"); idx >= 0 && idx == len(text)-1 {
+		multiplier = 1 << (10 * (strings.IndexByte("KMGT", text[idx]) + 1))
+		text = text[:idx]
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int(value * float64(multiplier)), nil
+}
@@ -0,0 +1,21 @@
+package config
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for text, expected := range map[string]int{
+		"":        0,
+		"1500":    1500,
+		"64K":     64 * 1024,
+		"20MB":    20 * 1024 * 1024,
+		"1.5 GiB": 3 * 512 * 1024 * 1024,
+	} {
+		size, err := ParseSize(text)
+		if err != nil || size != expected {
+			t.Errorf("ParseSize(%q) = %d, %v; expected %d", text, size, err, expected)
+		}
+	}
+	if _, err := ParseSize("lots"); err == nil {
+		t.Error("expected error for invalid size")
+	}
+}
@@ -1,6 +1,12 @@
 package fs
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
 
 type Events interface {
 	Send(msg any)
@@ -9,25 +15,119 @@ type Events interface {
 type FS interface {
 	Root() string
 	Scan(events Events)
-	Remove(path string)
+	Rescan(events Events)
+	SetPaused(paused bool)
+	// Remove, Trash and Link act on file only while it and the copy that
+	// is kept are unchanged since they were scanned; otherwise they fail
+	// with a *ChangedError. A change that was made but not recorded in
+	// the audit log fails with ErrNotAudited.
+	Remove(file, original FileMeta) error
+	Trash(file, original FileMeta) error
+	Link(file, target FileMeta) error
 }
 
+// FullHashSize is the largest file size whose hash covers the whole content;
+// larger files are hashed by their head and tail.
+const FullHashSize = 512 * 1024
+
 type FileMeta struct {
 	Idx     int
 	Path    string
 	Size    int
 	ModTime time.Time
 	Hash    string
+	Inode   uint64
 }
 
 // Events
 
 type FileMetas []FileMeta
 
+type ScanProgress struct {
+	Dirs  int
+	Files int
+	Bytes int
+}
+
+type ArchiveScanned struct {
+}
+
 type FileHashed struct {
 	Path string
 	Hash string
+	Size int
 }
 
 type ArchiveHashed struct {
 }
+
+type FileAdded FileMeta
+
+type FileModified FileMeta
+
+type FileRemoved struct {
+	Path string
+}
+
+// ChangedError reports a file that no longer matches its scanned state.
+// File holds its current state; Removed is set when the file is gone.
+type ChangedError struct {
+	File    FileMeta
+	Removed bool
+}
+
+func (e *ChangedError) Error() string {
+	if e.Removed {
+		return fmt.Sprintf("%q was removed since it was scanned", e.File.Path)
+	}
+	return fmt.Sprintf("%q changed since it was scanned", e.File.Path)
+}
+
+// ErrReadOnly is returned for changes to an archive opened read-only.
+var ErrReadOnly = errors.New("archive is read-only")
+
+// ErrProtected is returned for changes to files under a protected path.
+var ErrProtected = errors.New("path is protected")
+
+// ErrNotAudited is wrapped by the error of a change that was made
+// but could not be recorded in the audit log.
+var ErrNotAudited = errors.New("change not recorded in the audit log")
+
+// Protected lists archive relative paths whose files are never removed.
+type Protected []string
+
+// NewProtected makes paths relative to the archive root; paths outside it are dropped.
+func NewProtected(root string, paths []string) Protected {
+	result := Protected{}
+	for _, path := range paths {
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(root, path)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+				continue
+			}
+			path = rel
+		}
+		result = append(result, filepath.ToSlash(filepath.Clean(path)))
+	}
+	return result
+}
+
+// Contains reports whether path is one of the protected paths or lies under one.
+func (p Protected) Contains(path string) bool {
+	for _, protected := range p {
+		if protected == "." || path == protected || strings.HasPrefix(path, protected+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Within reports whether a protected path lies under the folder path.
+func (p Protected) Within(path string) bool {
+	for _, protected := range p {
+		if path == "" || strings.HasPrefix(protected, path+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -8,11 +8,13 @@ import (
 	"os"
 	"slices"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
 type FS struct {
-	root string
+	root   string
+	paused atomic.Bool
 }
 
 func New(path string) *FS {
@@ -27,22 +29,50 @@ func (fsys *FS) Scan(events fs.Events) {
 	go fsys.scan(events)
 }
 
-func (fsys *FS) Remove(path string) {
-	log.Println("removed", path)
+func (fsys *FS) Rescan(events fs.Events) {
+	go fsys.scan(events)
+}
+
+func (fsys *FS) SetPaused(paused bool) {
+	fsys.paused.Store(paused)
+}
+
+func (fsys *FS) Remove(file, original fs.FileMeta) error {
+	log.Println("removed", file.Path)
+	return nil
+}
+
+func (fsys *FS) Trash(file, original fs.FileMeta) error {
+	log.Println("trashed", file.Path)
+	return nil
+}
+
+func (fsys *FS) Link(file, target fs.FileMeta) error {
+	log.Println("linked", file.Path, "to", target.Path)
+	return nil
 }
 
 func (fsys *FS) scan(events fs.Events) {
 	time.Sleep(time.Second)
 	metas := readMetas()
+	progress := fs.ScanProgress{Dirs: 1}
 	for i := range metas {
 		metas[i].Hash = ""
+		progress.Files++
+		progress.Bytes += metas[i].Size
 	}
 	events.Send(metas)
+	events.Send(progress)
+	events.Send(fs.ArchiveScanned{})
 	metas = readMetas()
 	for _, file := range metas {
+		for fsys.paused.Load() {
+			time.Sleep(10 * time.Millisecond)
+		}
 		events.Send(fs.FileHashed{
 			Path: file.Path,
 			Hash: file.Hash,
+			Size: file.Size,
 		})
 		time.Sleep(time.Millisecond)
 	}
@@ -0,0 +1,95 @@
+package realfs
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const uuidDir = "/dev/disk/by-uuid"
+
+// CacheLocation selects where the hash cache of an archive is stored.
+type CacheLocation int
+
+const (
+	// CacheInArchive keeps the cache in .meta.csv at the archive root.
+	CacheInArchive CacheLocation = iota
+
+	// CacheExternal keeps the cache in the user cache directory,
+	// keyed by the archive's root path and filesystem.
+	CacheExternal
+)
+
+// ParseCacheLocation parses "archive" or "external"; empty means "archive".
+func ParseCacheLocation(text string) (CacheLocation, error) {
+	switch text {
+	case "", "archive":
+		return CacheInArchive, nil
+	case "external":
+		return CacheExternal, nil
+	}
+	return 0, fmt.Errorf("invalid cache location %q, want \"archive\" or \"external\"", text)
+}
+
+// metaPaths returns the path the hash cache is stored at and the path
+// of the other location, which is read when the first one is missing.
+// Storing the cache removes the other one, so that switching the
+// location migrates the cache.
+func (fsys *FS) metaPaths() (string, string) {
+	internal := filepath.Join(fsys.root, hashFileName)
+	external := externalMetaPath(fsys.root)
+	if fsys.options.ReadOnly || fsys.options.Cache == CacheExternal {
+		return external, internal
+	}
+	return internal, external
+}
+
+func (fsys *FS) dropCache(path string) {
+	err := os.Remove(path)
+	if err == nil {
+		log.Printf("migrated hash cache from %q\n", path)
+	} else if !errors.Is(err, iofs.ErrNotExist) {
+		log.Printf("Error: failed to remove stale hash cache %q: %#v\n", path, err)
+	}
+}
+
+// externalMetaPath returns where the hash cache of the archive at root is
+// kept outside of it. Caches are keyed by root and the filesystem's UUID,
+// so a cache stays behind in the cache directory when the archive moves or
+// its filesystem is recreated; such files are never read again and can be
+// deleted safely.
+func externalMetaPath(root string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(filesystemID(root) + "\x00" + root))
+	return filepath.Join(dir, "dedup", base64.RawURLEncoding.EncodeToString(sum[:12])+".csv")
+}
+
+// filesystemID identifies the filesystem holding root by its UUID when the
+// system lists one. Otherwise it is empty, leaving root alone to identify
+// the archive, as device numbers of network and btrfs filesystems change
+// between mounts.
+func filesystemID(root string) string {
+	info, err := os.Stat(root)
+	if err != nil {
+		return ""
+	}
+	dev := info.Sys().(*syscall.Stat_t).Dev
+
+	entries, _ := os.ReadDir(uuidDir)
+	for _, entry := range entries {
+		device, err := os.Stat(filepath.Join(uuidDir, entry.Name()))
+		if err == nil && device.Sys().(*syscall.Stat_t).Rdev == dev {
+			return "uuid:" + entry.Name()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package realfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dedup/fs"
+)
+
+func TestCacheMigration(t *testing.T) {
+	fsys, root := newTestFS(t, Options{})
+	writeFile(t, root, "a/x", "x")
+	internal, external := filepath.Join(root, hashFileName), externalMetaPath(root)
+
+	scan := func() (hashed int) {
+		events := &recorder{}
+		fsys.scanArchive(events)
+		for _, event := range events.events {
+			if is[fs.FileHashed](event) {
+				hashed++
+			}
+		}
+		return hashed
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	if hashed := scan(); hashed != 1 || !exists(internal) || exists(external) {
+		t.Fatalf("archive cache: hashed %d, internal %v, external %v", hashed, exists(internal), exists(external))
+	}
+
+	fsys.options.Cache = CacheExternal
+	if hashed := scan(); hashed != 0 || exists(internal) || !exists(external) {
+		t.Errorf("moved to external: hashed %d, internal %v, external %v", hashed, exists(internal), exists(external))
+	}
+
+	fsys.options.Cache = CacheInArchive
+	if hashed := scan(); hashed != 0 || !exists(internal) || exists(external) {
+		t.Errorf("moved back: hashed %d, internal %v, external %v", hashed, exists(internal), exists(external))
+	}
+
+	fsys.options.ReadOnly = true
+	if hashed := scan(); hashed != 0 || !exists(internal) || !exists(external) {
+		t.Errorf("read-only: hashed %d, internal %v, external %v", hashed, exists(internal), exists(external))
+	}
+}
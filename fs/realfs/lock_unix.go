@@ -0,0 +1,54 @@
+//go:build unix
+
+package realfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockArchive takes an exclusive advisory lock on path and records
+// the holding process in it. The lock lasts until the file is closed
+// or the process exits.
+func lockArchive(path string) (*os.File, error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		err = unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			holder, _ := os.ReadFile(path)
+			_ = file.Close()
+			return nil, fmt.Errorf("%w by %s", errLocked, strings.TrimSpace(string(holder)))
+		}
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		// The previous holder removes the file when it closes it;
+		// a lock on a removed file locks nothing.
+		if isCurrent(file, path) {
+			return recordHolder(file), nil
+		}
+		_ = file.Close()
+	}
+}
+
+func isCurrent(file *os.File, path string) bool {
+	opened, err1 := file.Stat()
+	current, err2 := os.Stat(path)
+	return err1 == nil && err2 == nil && os.SameFile(opened, current)
+}
+
+func recordHolder(file *os.File) *os.File {
+	host, _ := os.Hostname()
+	_ = file.Truncate(0)
+	_, _ = fmt.Fprintf(file, "pid %d on %s since %s\n", os.Getpid(), host, time.Now().Format(time.DateTime))
+	return file
+}
@@ -0,0 +1,52 @@
+//go:build unix
+
+package realfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockArchive(t *testing.T) {
+	fsys, root := newTestFS(t, Options{})
+	lockPath := filepath.Join(root, lockFileName)
+
+	_, err := New(root, Options{})
+	if !errors.Is(err, errLocked) || !strings.Contains(err.Error(), fmt.Sprint("pid ", os.Getpid())) {
+		t.Fatalf("second instance got %v", err)
+	}
+	forced, err := New(root, Options{Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = forced.Close()
+
+	if err := fsys.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lockPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+	fsys, err = New(root, Options{})
+	if err != nil {
+		t.Fatalf("reopening a closed archive: %v", err)
+	}
+	_ = fsys.Close()
+}
+
+func TestLockWithExternalCache(t *testing.T) {
+	fsys, root := newTestFS(t, Options{Cache: CacheExternal})
+	if _, err := os.Stat(filepath.Join(root, lockFileName)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file created in the archive: %v", err)
+	}
+	if _, err := os.Stat(externalMetaPath(root) + ".lock"); err != nil {
+		t.Errorf("no lock next to the external cache: %v", err)
+	}
+	if _, err := lockArchive(fsys.lock.Name()); !errors.Is(err, errLocked) {
+		t.Errorf("second lock got %v", err)
+	}
+}
@@ -1,27 +1,37 @@
 package realfs
 
 import (
+	"cmp"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	iofs "io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"golang.org/x/text/unicode/norm"
 
+	"dedup/audit"
 	"dedup/fs"
 )
 
 const hashFileName = ".meta.csv"
-const bufSize = 256 * 1024
+const trashDir = "~~~trash"
+const bufSize = fs.FullHashSize / 2
+const progressInterval = 100 * time.Millisecond
+const lockFileName = ".dedup.lock"
+
+var errLocked = errors.New("archive is locked")
 
 type meta struct {
 	inode uint64
@@ -29,11 +39,115 @@ type meta struct {
 }
 
 type FS struct {
-	root string
+	root     string
+	options  Options
+	watcher  *watcher
+	throttle *throttle
+	audit    *audit.Log
+	lock     *os.File
+
+	mu         sync.Mutex
+	files      map[string]*meta
+	storeTimer *time.Timer
 }
 
-func New(path string) *FS {
-	return &FS{root: path}
+type Options struct {
+	// Watch keeps the archive under inotify watch after the initial scan
+	// and reports files added, modified or removed by other processes.
+	Watch bool
+
+	// RateLimit caps hashing reads in bytes per second; zero means unlimited.
+	RateLimit int
+
+	// Protected paths are never removed, trashed or replaced by links.
+	Protected fs.Protected
+
+	// ReadOnly refuses every change to the archive and keeps
+	// the hash cache in the user cache directory.
+	ReadOnly bool
+
+	// Cache selects where the hash cache is stored. The archive lock
+	// is kept next to an external cache, outside the archive.
+	Cache CacheLocation
+
+	// AuditLog is the file every change to the archive is recorded in;
+	// empty means the default location inside the archive.
+	AuditLog string
+
+	// Force opens the archive even when another process holds its lock.
+	Force bool
+}
+
+// New opens the archive at path, locking it against other instances.
+func New(path string, options Options) (*FS, error) {
+	auditLog := options.AuditLog
+	if auditLog == "" {
+		auditLog = audit.DefaultPath(path)
+	}
+	fsys := &FS{
+		root:     path,
+		options:  options,
+		throttle: newThrottle(options.RateLimit),
+		audit:    audit.New(auditLog),
+		files:    map[string]*meta{},
+	}
+	if !options.ReadOnly {
+		if err := fsys.audit.Check(); err != nil {
+			return nil, fmt.Errorf("audit log is not writable: %w", err)
+		}
+	}
+
+	lockPath := filepath.Join(path, lockFileName)
+	if options.ReadOnly || options.Cache == CacheExternal {
+		lockPath = externalMetaPath(path) + ".lock"
+		if err := os.MkdirAll(filepath.Dir(lockPath), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	fsys.lock, err = lockArchive(lockPath)
+	if errors.Is(err, errLocked) && options.Force {
+		log.Printf("Warning: %q: %v, opening anyway\n", path, err)
+		err = nil
+	}
+	if errors.Is(err, errLocked) {
+		return nil, fmt.Errorf("%q: %w; use --force if the lock is stale", path, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock archive %q: %w", path, err)
+	}
+
+	if options.Watch {
+		fsys.watcher, err = newWatcher()
+		if err != nil {
+			logWatchError(path, err)
+		}
+	}
+	return fsys, nil
+}
+
+// Close stops watching, stores changes the watcher has not stored yet,
+// releases the lock on the archive and removes the lock file. A running
+// scan keeps the cache locked and stores it itself when it finishes.
+func (fsys *FS) Close() error {
+	if fsys.watcher != nil {
+		fsys.watcher.close()
+	}
+	if fsys.mu.TryLock() {
+		if fsys.storeTimer != nil && fsys.storeTimer.Stop() {
+			fsys.storeTimer = nil
+			_ = fsys.storeMeta()
+		}
+		fsys.mu.Unlock()
+	}
+
+	if fsys.lock == nil {
+		return nil
+	}
+	_ = os.Remove(fsys.lock.Name())
+	err := fsys.lock.Close()
+	fsys.lock = nil
+	return err
 }
 
 func (fsys *FS) Root() string {
@@ -44,22 +158,179 @@ func (fsys *FS) Scan(events fs.Events) {
 	go fsys.scan(events)
 }
 
-func (fsys *FS) Remove(path string) {
-	err := os.Remove(filepath.Join(fsys.root, path))
+func (fsys *FS) Rescan(events fs.Events) {
+	go func() {
+		fsys.mu.Lock()
+		defer fsys.mu.Unlock()
+		fsys.scanArchive(events)
+	}()
+}
+
+func (fsys *FS) SetPaused(paused bool) {
+	fsys.throttle.setPaused(paused)
+}
+
+func (fsys *FS) Remove(file, original fs.FileMeta) error {
+	if err := fsys.verify(file, original); err != nil {
+		return err
+	}
+	path := filepath.Join(fsys.root, file.Path)
+	info, err := os.Lstat(path)
+	isDir := err == nil && info.IsDir()
+	err = os.Remove(path)
 	if err != nil {
-		log.Printf("failed to remove file %q: %#v", path, err)
+		log.Printf("failed to remove file %q: %#v", file.Path, err)
+		return err
 	}
-	log.Println("removed", path)
+	log.Println("removed", file.Path)
+	if isDir {
+		// Folders are removed once their files are; only the files are recorded.
+		return nil
+	}
+	return fsys.record(audit.OpRemove, file, original)
+}
+
+// Trash moves the file into the archive's trash folder, keeping its relative path.
+func (fsys *FS) Trash(file, original fs.FileMeta) error {
+	if err := fsys.verify(file, original); err != nil {
+		return err
+	}
+	path := file.Path
+	trashPath := filepath.Join(fsys.root, trashDir, path)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(trashPath); err != nil {
+			break
+		}
+		trashPath = filepath.Join(fsys.root, trashDir, fmt.Sprintf("%s.%d", path, i))
+	}
+	err := os.MkdirAll(filepath.Dir(trashPath), 0o755)
+	if err == nil {
+		err = os.Rename(filepath.Join(fsys.root, path), trashPath)
+	}
+	if err != nil {
+		log.Printf("failed to trash file %q: %#v", path, err)
+		return err
+	}
+	log.Println("trashed", path)
+	return fsys.record(audit.OpTrash, file, original)
+}
+
+// Link replaces the file with a hard link to target.
+func (fsys *FS) Link(file, target fs.FileMeta) error {
+	if err := fsys.verify(file, target); err != nil {
+		return err
+	}
+	absPath := filepath.Join(fsys.root, file.Path)
+	tmpPath := filepath.Join(filepath.Dir(absPath), "."+filepath.Base(absPath)+".dedup-link")
+	err := os.Link(filepath.Join(fsys.root, target.Path), tmpPath)
+	if err == nil {
+		err = os.Rename(tmpPath, absPath)
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}
+	if err != nil {
+		log.Printf("failed to link file %q to %q: %#v", file.Path, target.Path, err)
+		return err
+	}
+	log.Println("linked", file.Path, "to", target.Path)
+	return fsys.record(audit.OpLink, file, target)
+}
+
+// record appends a completed change to the audit log.
+func (fsys *FS) record(op string, file, kept fs.FileMeta) error {
+	err := fsys.audit.Append(audit.Record{
+		Op:   op,
+		Path: file.Path,
+		Size: file.Size,
+		Hash: file.Hash,
+		Kept: kept.Path,
+	})
+	if err != nil {
+		log.Printf("Error: failed to write audit log %q: %#v\n", fsys.audit.Path(), err)
+		return fmt.Errorf("%w: %w", fs.ErrNotAudited, err)
+	}
+	return nil
+}
+
+// verify checks that both a duplicate and the copy kept in its place still
+// match their scanned state, re-hashing a file when its size, modification
+// time or inode changed.
+func (fsys *FS) verify(file, original fs.FileMeta) error {
+	if fsys.options.ReadOnly {
+		return fs.ErrReadOnly
+	}
+	if fsys.options.Protected.Contains(file.Path) {
+		log.Printf("refused to change protected %q", file.Path)
+		return fmt.Errorf("%q: %w", file.Path, fs.ErrProtected)
+	}
+	for _, meta := range []fs.FileMeta{original, file} {
+		if err := fsys.verifyFile(meta); err != nil {
+			log.Printf("refused to change %q: %v", file.Path, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (fsys *FS) verifyFile(meta fs.FileMeta) error {
+	info, err := os.Lstat(filepath.Join(fsys.root, meta.Path))
+	if errors.Is(err, iofs.ErrNotExist) {
+		return &fs.ChangedError{File: meta, Removed: true}
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+
+	current := fs.FileMeta{
+		Path:    meta.Path,
+		Size:    int(info.Size()),
+		ModTime: info.ModTime().UTC().Round(time.Second),
+		Inode:   info.Sys().(*syscall.Stat_t).Ino,
+		Hash:    meta.Hash,
+	}
+	if current.Size == meta.Size && current.ModTime.Equal(meta.ModTime) && (meta.Inode == 0 || current.Inode == meta.Inode) {
+		return nil
+	}
+	current.Hash = fsys.hashFile(&current, nil)
+	if current.Hash == "" || current.Hash != meta.Hash {
+		return &fs.ChangedError{File: current}
+	}
+	return nil
 }
 
 func (fsys *FS) scan(events fs.Events) {
+	fsys.mu.Lock()
+	fsys.scanArchive(events)
+	fsys.mu.Unlock()
+
+	if fsys.watcher != nil {
+		fsys.watch(events)
+	}
+}
+
+func (fsys *FS) scanArchive(events fs.Events) {
 	metas := fs.FileMetas{}
+	progress := fs.ScanProgress{}
+	lastSent := time.Now()
+	flush := func() {
+		events.Send(metas)
+		events.Send(progress)
+		metas = fs.FileMetas{}
+		lastSent = time.Now()
+	}
 
 	metaMap := fsys.readMeta()
-	var metaSlice []*meta
+	for _, meta := range fsys.files {
+		metaMap[meta.inode] = meta.file
+	}
+	fsys.files = map[string]*meta{}
 
 	defer func() {
-		_ = fsys.storeMeta(fsys.root, metaSlice)
+		_ = fsys.storeMeta()
 		events.Send(fs.ArchiveHashed{})
 	}()
 
@@ -68,6 +339,12 @@ func (fsys *FS) scan(events fs.Events) {
 		if d.IsDir() && strings.HasPrefix(d.Name(), "~~~") {
 			return iofs.SkipDir
 		}
+		if d.IsDir() {
+			progress.Dirs++
+			if fsys.watcher != nil {
+				fsys.watcher.addDir(fsys.root, path)
+			}
+		}
 		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
 			return nil
 		}
@@ -98,6 +375,7 @@ func (fsys *FS) scan(events fs.Events) {
 		}
 
 		sys := info.Sys().(*syscall.Stat_t)
+		file.Inode = sys.Ino
 		readMeta := metaMap[sys.Ino]
 		if readMeta != nil && readMeta.ModTime == modTime && readMeta.Size == size {
 			file.Hash = readMeta.Hash
@@ -105,12 +383,17 @@ func (fsys *FS) scan(events fs.Events) {
 
 		metas = append(metas, *file)
 
-		metaSlice = append(metaSlice, &meta{
+		fsys.files[file.Path] = &meta{
 			inode: sys.Ino,
 			file:  file,
-		})
+		}
 		metaMap[sys.Ino] = file
 
+		progress.Files++
+		progress.Bytes += size
+		if time.Since(lastSent) >= progressInterval {
+			flush()
+		}
 		return nil
 	})
 
@@ -119,25 +402,45 @@ func (fsys *FS) scan(events fs.Events) {
 		return
 	}
 
-	events.Send(metas)
+	flush()
+	events.Send(fs.ArchiveScanned{})
 
-	for _, meta := range metaSlice {
-		if meta.file.Hash != "" {
-			continue
-		}
-		log.Printf("hash %q\n", meta.file.Path)
-		meta.file.Hash = fsys.hashFile(meta.file)
-		events.Send(fs.FileHashed{
-			Path: meta.file.Path,
-			Hash: meta.file.Hash,
-		})
+	for _, meta := range fsys.sortedMetas() {
+		fsys.hashMeta(events, meta)
 	}
 }
 
+func (fsys *FS) hashMeta(events fs.Events, meta *meta) {
+	if meta.file.Hash != "" {
+		return
+	}
+	log.Printf("hash %q\n", meta.file.Path)
+	meta.file.Hash = fsys.hashFile(meta.file, fsys.throttle)
+	events.Send(fs.FileHashed{
+		Path: meta.file.Path,
+		Hash: meta.file.Hash,
+		Size: meta.file.Size,
+	})
+}
+
+func (fsys *FS) sortedMetas() []*meta {
+	result := make([]*meta, 0, len(fsys.files))
+	for _, meta := range fsys.files {
+		result = append(result, meta)
+	}
+	slices.SortFunc(result, func(a, b *meta) int {
+		return cmp.Compare(a.file.Path, b.file.Path)
+	})
+	return result
+}
+
 func (fsys *FS) readMeta() map[uint64]*fs.FileMeta {
 	metas := map[uint64]*fs.FileMeta{}
-	absHashFileName := filepath.Join(fsys.root, hashFileName)
-	hashInfoFile, err := os.Open(absHashFileName)
+	primary, secondary := fsys.metaPaths()
+	hashInfoFile, err := os.Open(primary)
+	if err != nil {
+		hashInfoFile, err = os.Open(secondary)
+	}
 	if err != nil {
 		return metas
 	}
@@ -176,7 +479,8 @@ func (fsys *FS) readMeta() map[uint64]*fs.FileMeta {
 	return metas
 }
 
-func (s *FS) storeMeta(root string, metas []*meta) error {
+func (fsys *FS) storeMeta() error {
+	metas := fsys.sortedMetas()
 	result := make([][]string, 1, len(metas)+1)
 	result[0] = []string{"INode", "Name", "Size", "ModTime", "Hash"}
 
@@ -193,18 +497,24 @@ func (s *FS) storeMeta(root string, metas []*meta) error {
 		})
 	}
 
-	absHashFileName := filepath.Join(root, hashFileName)
-	hashInfoFile, err := os.Create(absHashFileName)
-
+	path, other := fsys.metaPaths()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	hashInfoFile, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	err = csv.NewWriter(hashInfoFile).WriteAll(result)
 	_ = hashInfoFile.Close()
+	if err == nil && !fsys.options.ReadOnly {
+		fsys.dropCache(other)
+	}
 	return err
 }
 
-func (fsys *FS) hashFile(meta *fs.FileMeta) string {
+// hashFile hashes the head and the tail of a file, reading through limit unless it is nil.
+func (fsys *FS) hashFile(meta *fs.FileMeta, limit *throttle) string {
 	hash := sha256.New()
 	buf := make([]byte, bufSize)
 	path := filepath.Join(fsys.root, meta.Path)
@@ -220,6 +530,7 @@ func (fsys *FS) hashFile(meta *fs.FileMeta) string {
 	if meta.Size > 2*bufSize {
 		offset = meta.Size - bufSize
 	}
+	limit.wait(min(meta.Size, bufSize))
 	nr, er := file.Read(buf)
 	if er != nil && er != io.EOF {
 		log.Printf("Error: failed to scan archive %q: %#v\n", fsys.root, err)
@@ -227,6 +538,7 @@ func (fsys *FS) hashFile(meta *fs.FileMeta) string {
 	}
 	hash.Write(buf[0:nr])
 	if meta.Size > bufSize {
+		limit.wait(min(meta.Size-offset, bufSize))
 		nr, er := file.ReadAt(buf, int64(offset))
 		if er != nil && er != io.EOF {
 			log.Printf("Error: failed to scan archive %q: %#v\n", fsys.root, err)
@@ -0,0 +1,246 @@
+package realfs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"dedup/audit"
+	"dedup/fs"
+)
+
+// recorder collects the events sent by an FS.
+type recorder struct {
+	mu     sync.Mutex
+	events []any
+	read   int
+}
+
+func (r *recorder) Send(event any) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+}
+
+// next returns the first event not returned before that satisfies match,
+// waiting a few seconds for it.
+func (r *recorder) next(t *testing.T, match func(event any) bool) any {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		r.mu.Lock()
+		for r.read < len(r.events) {
+			event := r.events[r.read]
+			r.read++
+			if match(event) {
+				r.mu.Unlock()
+				return event
+			}
+		}
+		r.mu.Unlock()
+	}
+	t.Fatal("timed out waiting for an event")
+	return nil
+}
+
+func is[T any](event any) bool {
+	_, ok := event.(T)
+	return ok
+}
+
+// newTestFS opens an empty archive in a temporary folder,
+// keeping external caches in another one.
+func newTestFS(t *testing.T, options Options) (*FS, string) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := t.TempDir()
+	fsys, err := New(root, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// Keep fsys locked, so that rescans and the watcher stop
+		// writing to the archive before it is removed.
+		fsys.mu.Lock()
+		_ = fsys.Close()
+	})
+	return fsys, root
+}
+
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	path = filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stat returns the current state of the file at path, hashed.
+func stat(t *testing.T, fsys *FS, path string) fs.FileMeta {
+	t.Helper()
+	info, err := os.Lstat(filepath.Join(fsys.root, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := fs.FileMeta{
+		Path:    path,
+		Size:    int(info.Size()),
+		ModTime: info.ModTime().UTC().Round(time.Second),
+		Inode:   info.Sys().(*syscall.Stat_t).Ino,
+	}
+	meta.Hash = fsys.hashFile(&meta, nil)
+	return meta
+}
+
+func TestVerifyFile(t *testing.T) {
+	fsys, root := newTestFS(t, Options{})
+	path := filepath.Join(root, "x")
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		change  func()
+		changed bool
+		removed bool
+	}{
+		{"unchanged", func() {}, false, false},
+		{"touched", func() { _ = os.Chtimes(path, later, later) }, false, false},
+		{"replaced by a copy", func() {
+			writeFile(t, root, "y", "content")
+			_ = os.Rename(filepath.Join(root, "y"), path)
+		}, false, false},
+		{"modified", func() {
+			writeFile(t, root, "x", "CONTENT")
+			_ = os.Chtimes(path, later, later)
+		}, true, false},
+		{"grown", func() { writeFile(t, root, "x", "more content") }, true, false},
+		{"removed", func() { _ = os.Remove(path) }, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writeFile(t, root, "x", "content")
+			meta := stat(t, fsys, "x")
+			test.change()
+
+			err := fsys.verifyFile(meta)
+			var changed *fs.ChangedError
+			if errors.As(err, &changed) != test.changed {
+				t.Fatalf("got %v", err)
+			}
+			if !test.changed && err != nil {
+				t.Fatal(err)
+			}
+			if test.changed && changed.Removed != test.removed {
+				t.Errorf("removed = %v, want %v", changed.Removed, test.removed)
+			}
+			if test.changed && !test.removed && (changed.File.Hash == "" || changed.File.Hash == meta.Hash) {
+				t.Errorf("changed file reported with hash %q", changed.File.Hash)
+			}
+		})
+	}
+}
+
+func TestRemoveRefusesChangedFiles(t *testing.T) {
+	fsys, root := newTestFS(t, Options{})
+	writeFile(t, root, "a", "content")
+	writeFile(t, root, "b", "content")
+	original, dup := stat(t, fsys, "a"), stat(t, fsys, "b")
+
+	later := time.Now().Add(time.Hour)
+	writeFile(t, root, "a", "changed")
+	_ = os.Chtimes(filepath.Join(root, "a"), later, later)
+	if err := fsys.Remove(dup, original); err == nil {
+		t.Fatal("removed a copy of a changed file")
+	}
+	if _, err := os.Stat(filepath.Join(root, "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	original = stat(t, fsys, "a")
+	_ = os.Chtimes(filepath.Join(root, "b"), later, later)
+	if err := fsys.Remove(dup, original); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "b")); !errors.Is(err, iofs.ErrNotExist) {
+		t.Errorf("copy was not removed: %v", err)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	fsys, root := newTestFS(t, Options{})
+	if _, err := os.Stat(fsys.audit.Path()); !errors.Is(err, iofs.ErrNotExist) {
+		t.Errorf("audit log created before the first change: %v", err)
+	}
+	blocked := filepath.Join(root, "blocked", "audit.jsonl")
+	writeFile(t, root, "blocked", "not a folder")
+	if _, err := New(root, Options{AuditLog: blocked, Force: true}); err == nil {
+		t.Error("opened with an unwritable audit log")
+	}
+	readOnly, err := New(root, Options{AuditLog: blocked, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("read-only archive needs no audit log: %v", err)
+	}
+	_ = readOnly.Close()
+
+	writeFile(t, root, "a/x", "content")
+	writeFile(t, root, "b/x", "content")
+	original, dup := stat(t, fsys, "a/x"), stat(t, fsys, "b/x")
+	if err := fsys.Remove(dup, original); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Remove(fs.FileMeta{Path: "b"}, fs.FileMeta{Path: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := audit.Read(fsys.audit.Path(), audit.Query{})
+	if err != nil || len(records) != 1 || records[0].Path != "b/x" {
+		t.Errorf("expected the removal of b/x only, got %v, %v", records, err)
+	}
+
+	writeFile(t, root, "c/x", "content")
+	dup = stat(t, fsys, "c/x")
+	fsys.audit = audit.New(blocked)
+	if err := fsys.Remove(dup, original); !errors.Is(err, fs.ErrNotAudited) {
+		t.Errorf("unrecorded removal returned %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "c", "x")); !errors.Is(err, iofs.ErrNotExist) {
+		t.Errorf("copy was not removed: %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	fsys, root := newTestFS(t, Options{ReadOnly: true})
+	writeFile(t, root, "a", "content")
+	writeFile(t, root, "b", "content")
+	events := &recorder{}
+	fsys.Scan(events)
+	events.next(t, is[fs.ArchiveHashed])
+
+	original, dup := stat(t, fsys, "a"), stat(t, fsys, "b")
+	for name, change := range map[string]func(file, original fs.FileMeta) error{
+		"removed": fsys.Remove, "trashed": fsys.Trash, "linked": fsys.Link,
+	} {
+		if err := change(dup, original); !errors.Is(err, fs.ErrReadOnly) {
+			t.Errorf("%s a copy in a read-only archive: %v", name, err)
+		}
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, []string{"a", "b"}) {
+		t.Errorf("read-only archive holds %v", names)
+	}
+}
@@ -0,0 +1,67 @@
+package realfs
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle gates hashing I/O: it blocks while hashing is paused
+// and spaces reads out to stay under the configured rate.
+type throttle struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+	rate   int
+
+	windowStart time.Time
+	windowBytes int
+	lastRead    time.Time
+}
+
+func newThrottle(rate int) *throttle {
+	t := &throttle{rate: rate}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+func (t *throttle) setPaused(paused bool) {
+	t.mu.Lock()
+	t.paused = paused
+	t.mu.Unlock()
+	t.cond.Broadcast()
+}
+
+// wait blocks until n more bytes may be read. A nil throttle never blocks.
+func (t *throttle) wait(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	for t.paused {
+		t.cond.Wait()
+		t.windowStart = time.Time{}
+	}
+	if t.rate <= 0 {
+		t.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(t.lastRead) > time.Second {
+		t.windowStart = time.Time{}
+	}
+	if t.windowStart.IsZero() {
+		t.windowStart = now
+		t.windowBytes = 0
+	}
+	t.windowBytes += n
+	delay := time.Until(t.windowStart.Add(time.Duration(float64(t.windowBytes) / float64(t.rate) * float64(time.Second))))
+	t.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	t.mu.Lock()
+	t.lastRead = time.Now()
+	t.mu.Unlock()
+}
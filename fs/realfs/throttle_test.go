@@ -0,0 +1,42 @@
+package realfs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleRate(t *testing.T) {
+	var unlimited *throttle
+	unlimited.wait(1 << 30)
+
+	limit := newThrottle(4 * bufSize)
+	start := time.Now()
+	for range 3 {
+		limit.wait(bufSize)
+	}
+	if elapsed := time.Since(start); elapsed < 600*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("read 3/4 of the rate in %v", elapsed)
+	}
+}
+
+func TestThrottlePause(t *testing.T) {
+	limit := newThrottle(0)
+	limit.setPaused(true)
+	done := make(chan struct{})
+	go func() {
+		limit.wait(bufSize)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("read while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	limit.setPaused(false)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("read still blocked after resuming")
+	}
+}
@@ -0,0 +1,192 @@
+package realfs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+
+	"dedup/fs"
+)
+
+// storeDelay is how long changes seen by the watcher wait before the hash
+// cache is rewritten, so that a burst of changes rewrites it once.
+const storeDelay = 10 * time.Second
+
+var errWatcherClosed = errors.New("watcher is closed")
+
+type change struct {
+	path     string
+	isDir    bool
+	removed  bool
+	overflow bool
+}
+
+// batch collects the effects of one read from the watcher.
+type batch struct {
+	events  fs.Events
+	pending []*meta
+	removed map[uint64]*fs.FileMeta
+	changed bool
+}
+
+func (fsys *FS) watch(events fs.Events) {
+	for {
+		changes, err := fsys.watcher.read()
+		if errors.Is(err, errWatcherClosed) {
+			return
+		}
+		if err != nil {
+			logWatchError(fsys.root, err)
+			return
+		}
+
+		fsys.mu.Lock()
+		fsys.applyChanges(events, changes)
+		fsys.mu.Unlock()
+	}
+}
+
+func (fsys *FS) applyChanges(events fs.Events, changes []change) {
+	b := &batch{events: events, removed: map[uint64]*fs.FileMeta{}}
+	for _, change := range changes {
+		switch {
+		case change.overflow:
+			log.Printf("Error: watch queue of archive %q overflowed, rescanning\n", fsys.root)
+			fsys.scanArchive(events)
+			return
+		case change.removed:
+			fsys.removePath(b, change.path)
+		case change.isDir:
+			fsys.addPath(b, change.path)
+		default:
+			fsys.updatePath(b, change.path)
+		}
+	}
+	if !b.changed {
+		return
+	}
+
+	for _, meta := range b.pending {
+		if fsys.files[meta.file.Path] == meta {
+			fsys.hashMeta(events, meta)
+		}
+	}
+	fsys.storeLater()
+	events.Send(fs.ArchiveHashed{})
+}
+
+// storeLater stores the hash cache after storeDelay unless a store is
+// already due. Called with fsys.mu held.
+func (fsys *FS) storeLater() {
+	if fsys.storeTimer != nil {
+		return
+	}
+	fsys.storeTimer = time.AfterFunc(storeDelay, func() {
+		fsys.mu.Lock()
+		defer fsys.mu.Unlock()
+		fsys.storeTimer = nil
+		_ = fsys.storeMeta()
+	})
+}
+
+func (fsys *FS) addPath(b *batch, path string) {
+	osfs := os.DirFS(fsys.root)
+	err := iofs.WalkDir(osfs, path, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), "~~~") {
+				return iofs.SkipDir
+			}
+			fsys.watcher.addDir(fsys.root, path)
+			return nil
+		}
+		if d.Type().IsRegular() && !strings.HasPrefix(d.Name(), ".") {
+			fsys.updatePath(b, norm.NFC.String(path))
+		}
+		return nil
+	})
+	if err != nil {
+		logWatchError(fsys.root, err)
+	}
+}
+
+func (fsys *FS) updatePath(b *batch, path string) {
+	info, err := os.Lstat(filepath.Join(fsys.root, path))
+	if errors.Is(err, iofs.ErrNotExist) || err == nil && info.Mode().IsRegular() && info.Size() == 0 {
+		fsys.removePath(b, path)
+		return
+	}
+	if err != nil {
+		logWatchError(fsys.root, err)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		return
+	}
+
+	size := int(info.Size())
+	modTime := info.ModTime().UTC().Round(time.Second)
+	inode := info.Sys().(*syscall.Stat_t).Ino
+
+	old := fsys.files[path]
+	if old != nil && old.inode == inode && old.file.Size == size && old.file.ModTime == modTime {
+		return
+	}
+
+	file := &fs.FileMeta{
+		Path:    path,
+		Size:    size,
+		ModTime: modTime,
+		Inode:   inode,
+	}
+	if moved := b.removed[inode]; moved != nil && moved.Size == size && moved.ModTime == modTime {
+		file.Hash = moved.Hash
+	}
+
+	meta := &meta{inode: inode, file: file}
+	fsys.files[path] = meta
+	b.changed = true
+	if file.Hash == "" {
+		b.pending = append(b.pending, meta)
+	}
+
+	if old == nil {
+		log.Printf("watch: added %q\n", path)
+		b.events.Send(fs.FileAdded(*file))
+	} else {
+		log.Printf("watch: modified %q\n", path)
+		b.events.Send(fs.FileModified(*file))
+	}
+}
+
+func (fsys *FS) removePath(b *batch, path string) {
+	prefix := path + "/"
+	removed := false
+	for filePath, meta := range fsys.files {
+		if filePath == path || strings.HasPrefix(filePath, prefix) {
+			b.removed[meta.inode] = meta.file
+			delete(fsys.files, filePath)
+			removed = true
+		}
+	}
+	fsys.watcher.removeDir(path)
+
+	if removed {
+		log.Printf("watch: removed %q\n", path)
+		b.changed = true
+		b.events.Send(fs.FileRemoved{Path: path})
+	}
+}
+
+func logWatchError(root string, err error) {
+	log.Printf("Error: failed to watch archive %q: %#v\n", root, err)
+}
@@ -0,0 +1,140 @@
+package realfs
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/text/unicode/norm"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// watcher reads inotify events of the archive's folders. Folders are added
+// by scans while the watch goroutine reads, so dirs is guarded by mu.
+// Writing to stop wakes a blocked read, which then closes both descriptors.
+type watcher struct {
+	fd     int
+	stop   int
+	mu     sync.Mutex
+	dirs   map[int]string
+	closed bool
+}
+
+func newWatcher() (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	stop, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	return &watcher{fd: fd, stop: stop, dirs: map[int]string{}}, nil
+}
+
+func (w *watcher) addDir(root, path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	wd, err := unix.InotifyAddWatch(w.fd, filepath.Join(root, path), watchMask)
+	if err != nil {
+		logWatchError(root, err)
+		return
+	}
+	w.dirs[wd] = path
+}
+
+func (w *watcher) removeDir(path string) {
+	prefix := path + "/"
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	for wd, dir := range w.dirs {
+		if dir == path || strings.HasPrefix(dir, prefix) {
+			_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// close makes the pending or next read return errWatcherClosed.
+func (w *watcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	_, _ = unix.Write(w.stop, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+}
+
+func (w *watcher) read() ([]change, error) {
+	fds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}, {Fd: int32(w.stop), Events: unix.POLLIN}}
+	_, err := unix.Poll(fds, -1)
+	for err == unix.EINTR {
+		_, err = unix.Poll(fds, -1)
+	}
+	if err == nil && fds[1].Revents != 0 {
+		w.mu.Lock()
+		_ = unix.Close(w.fd)
+		_ = unix.Close(w.stop)
+		w.mu.Unlock()
+		return nil, errWatcherClosed
+	}
+
+	buf := make([]byte, 64*1024)
+	n := 0
+	if err == nil {
+		n, err = unix.Read(w.fd, buf)
+		for err == unix.EINTR {
+			n, err = unix.Read(w.fd, buf)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var changes []change
+	for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+		offset = nameStart + int(event.Len)
+
+		if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+			changes = append(changes, change{overflow: true})
+			continue
+		}
+		if event.Mask&unix.IN_IGNORED != 0 {
+			delete(w.dirs, int(event.Wd))
+			continue
+		}
+		dir, ok := w.dirs[int(event.Wd)]
+		if !ok || name == "" {
+			continue
+		}
+
+		isDir := event.Mask&unix.IN_ISDIR != 0
+		if isDir && strings.HasPrefix(name, "~~~") || !isDir && strings.HasPrefix(name, ".") {
+			continue
+		}
+		changes = append(changes, change{
+			path:    norm.NFC.String(filepath.Join(dir, name)),
+			isDir:   isDir,
+			removed: event.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0,
+		})
+	}
+	return changes, nil
+}
@@ -0,0 +1,104 @@
+package realfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dedup/fs"
+)
+
+func TestWatch(t *testing.T) {
+	fsys, root := newTestFS(t, Options{Watch: true})
+	writeFile(t, root, "a/x", "x")
+	events := &recorder{}
+	fsys.Scan(events)
+	events.next(t, is[fs.ArchiveHashed])
+
+	writeFile(t, root, "b/y", "y")
+	added := events.next(t, is[fs.FileAdded]).(fs.FileAdded)
+	hashed := events.next(t, is[fs.FileHashed]).(fs.FileHashed)
+	if added.Path != "b/y" || hashed.Path != "b/y" || hashed.Hash == "" {
+		t.Errorf("new file reported as %v, hashed as %v", added, hashed)
+	}
+
+	if err := os.Remove(filepath.Join(root, "a", "x")); err != nil {
+		t.Fatal(err)
+	}
+	if removed := events.next(t, is[fs.FileRemoved]).(fs.FileRemoved); removed.Path != "a/x" {
+		t.Errorf("removed file reported as %v", removed)
+	}
+}
+
+func TestRescanWhileWatching(t *testing.T) {
+	fsys, root := newTestFS(t, Options{Watch: true})
+	writeFile(t, root, "a/x", "x")
+	events := &recorder{}
+	fsys.Scan(events)
+	events.next(t, is[fs.ArchiveHashed])
+
+	const folders = 20
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range folders {
+			path := filepath.Join(root, fmt.Sprint("d", i))
+			_ = os.Mkdir(path, 0o755)
+			_ = os.WriteFile(filepath.Join(path, "y"), []byte(fmt.Sprint(i)), 0o644)
+		}
+	}()
+	for range 5 {
+		fsys.Rescan(events)
+	}
+	<-done
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		fsys.mu.Lock()
+		n := len(fsys.files)
+		fsys.mu.Unlock()
+		if n == folders+1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d files, found %d", folders+1, n)
+		}
+	}
+}
+
+func TestCloseStopsWatching(t *testing.T) {
+	fsys, root := newTestFS(t, Options{Watch: true})
+	writeFile(t, root, "a/x", "x")
+	events := &recorder{}
+	fsys.Scan(events)
+	events.next(t, is[fs.ArchiveHashed])
+
+	cached := func() []string {
+		var paths []string
+		for _, meta := range fsys.readMeta() {
+			paths = append(paths, meta.Path)
+		}
+		return paths
+	}
+	writeFile(t, root, "b/y", "y")
+	events.next(t, is[fs.ArchiveHashed])
+	if paths := cached(); len(paths) != 1 {
+		t.Errorf("cache rewritten right after a watched change: %v", paths)
+	}
+
+	if err := fsys.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if paths := cached(); len(paths) != 2 {
+		t.Errorf("watched change not stored on close: %v", paths)
+	}
+
+	writeFile(t, root, "c/z", "z")
+	time.Sleep(200 * time.Millisecond)
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	for _, event := range events.events[events.read:] {
+		t.Errorf("event %v sent after close", event)
+	}
+}
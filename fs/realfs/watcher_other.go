@@ -0,0 +1,21 @@
+//go:build !linux
+
+package realfs
+
+import "errors"
+
+type watcher struct{}
+
+func newWatcher() (*watcher, error) {
+	return nil, errors.New("watching is only supported on linux")
+}
+
+func (w *watcher) addDir(root, path string) {}
+
+func (w *watcher) removeDir(path string) {}
+
+func (w *watcher) close() {}
+
+func (w *watcher) read() ([]change, error) {
+	return nil, errors.New("watching is only supported on linux")
+}